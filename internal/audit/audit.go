@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	OutputStdout = "stdout"
+
+	FieldHost     = "host"
+	FieldPath     = "path"
+	FieldUsername = "username"
+	FieldUID      = "uid"
+
+	redacted = "[redacted]"
+)
+
+// RedactableFields are the entry fields that can be redacted.
+//
+//nolint:gochecknoglobals
+var RedactableFields = []string{FieldHost, FieldPath, FieldUsername, FieldUID}
+
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Host     string    `json:"host"`
+	Path     string    `json:"path"`
+	Method   string    `json:"method"`
+	Rule     string    `json:"rule"`
+	Decision string    `json:"decision"`
+	Cached   bool      `json:"cached"`
+	Username string    `json:"username,omitempty"`
+	UID      string    `json:"uid,omitempty"`
+}
+
+type Logger struct {
+	config *Config
+	redact map[string]bool
+
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func New(context context.Context, config *Config) (*Logger, error) {
+	if config.Output == OutputStdout {
+		return NewWithWriter(config, os.Stdout), nil
+	}
+
+	f, err := os.OpenFile(config.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrLoggerCreate, err)
+	}
+
+	l := NewWithWriter(config, f)
+
+	// close the file when the plugin is discarded
+	go func() {
+		<-context.Done()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		_ = f.Close()
+		l.writer = io.Discard
+	}()
+
+	return l, nil
+}
+
+func NewWithWriter(config *Config, writer io.Writer) *Logger {
+	l := &Logger{
+		config: config,
+		redact: make(map[string]bool, len(config.Redact)),
+		writer: writer,
+	}
+
+	for _, field := range config.Redact {
+		l.redact[field] = true
+	}
+
+	return l
+}
+
+// Log writes an entry as a single json line, unless it is sampled out.
+func (l *Logger) Log(entry *Entry) {
+	if l.config.SampleRate < 1 && rand.Float64() >= l.config.SampleRate { //nolint:gosec
+		return
+	}
+
+	if l.redact[FieldHost] {
+		entry.Host = redacted
+	}
+
+	if l.redact[FieldPath] {
+		entry.Path = redacted
+	}
+
+	if l.redact[FieldUsername] && entry.Username != "" {
+		entry.Username = redacted
+	}
+
+	if l.redact[FieldUID] && entry.UID != "" {
+		entry.UID = redacted
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// entries are written at once, so lines are never interleaved
+	_, _ = l.writer.Write(append(data, '\n'))
+}
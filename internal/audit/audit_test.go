@@ -0,0 +1,112 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/audit"
+)
+
+func newEntry() *audit.Entry {
+	return &audit.Entry{
+		Time:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Host:     "example.com",
+		Path:     "/users",
+		Method:   "GET",
+		Rule:     "^/.*$",
+		Decision: "allowed",
+		Cached:   true,
+		Username: "jdoe",
+		UID:      "5f8e1b6c",
+	}
+}
+
+func TestLogger(t *testing.T) {
+	t.Run("write json lines", func(t *testing.T) {
+		var b bytes.Buffer
+		logger := audit.NewWithWriter(&audit.Config{SampleRate: 1}, &b)
+
+		logger.Log(newEntry())
+		logger.Log(newEntry())
+
+		lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected 2 lines, got %d", len(lines))
+		}
+
+		expected := `{"time":"2024-01-01T00:00:00Z","host":"example.com","path":"/users","method":"GET",` +
+			`"rule":"^/.*$","decision":"allowed","cached":true,"username":"jdoe","uid":"5f8e1b6c"}`
+		if lines[0] != expected {
+			t.Errorf("expected line %s, got %s", expected, lines[0])
+		}
+	})
+
+	t.Run("redact fields", func(t *testing.T) {
+		var b bytes.Buffer
+		logger := audit.NewWithWriter(&audit.Config{
+			SampleRate: 1,
+			Redact:     []string{"path", "username", "uid"},
+		}, &b)
+
+		logger.Log(newEntry())
+
+		var entry audit.Entry
+		if err := json.Unmarshal(b.Bytes(), &entry); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if entry.Host != "example.com" {
+			t.Errorf("expected host not to be redacted, got %s", entry.Host)
+		}
+
+		for name, value := range map[string]string{"path": entry.Path, "username": entry.Username, "uid": entry.UID} {
+			if value != "[redacted]" {
+				t.Errorf("expected %s to be redacted, got %s", name, value)
+			}
+		}
+	})
+
+	t.Run("sample entries", func(t *testing.T) {
+		var b bytes.Buffer
+		logger := audit.NewWithWriter(&audit.Config{SampleRate: 0.5}, &b)
+
+		for i := 0; i < 1000; i++ {
+			logger.Log(newEntry())
+		}
+
+		// check that roughly half of the entries are written
+		lines := strings.Count(b.String(), "\n")
+		if lines < 350 || lines > 650 {
+			t.Errorf("expected about 500 lines, got %d", lines)
+		}
+	})
+
+	t.Run("write to file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.log")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		logger, err := audit.New(ctx, &audit.Config{Output: path, SampleRate: 1})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		logger.Log(newEntry())
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if strings.Count(string(data), "\n") != 1 {
+			t.Errorf("expected 1 line, got %q", data)
+		}
+	})
+}
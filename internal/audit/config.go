@@ -0,0 +1,7 @@
+package audit
+
+type Config struct {
+	Output     string
+	SampleRate float64
+	Redact     []string
+}
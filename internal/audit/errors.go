@@ -0,0 +1,7 @@
+package audit
+
+import (
+	"errors"
+)
+
+var ErrLoggerCreate = errors.New("failed to create audit logger")
@@ -0,0 +1,141 @@
+package authentik
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type BreakerState int
+
+const (
+	BreakerClosed BreakerState = iota
+	BreakerOpen
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+var ErrCircuitOpen = errors.New("authentik circuit breaker is open")
+
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrCircuitOpen, e.RetryAfter)
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func (b *breaker) Allow() error {
+	if b.threshold == 0 {
+		// breaker is disabled
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if wait := b.cooldown - time.Since(b.openedAt); wait > 0 {
+			return &CircuitOpenError{RetryAfter: wait}
+		}
+
+		// cool down elapsed, let a single probe through
+		b.state = BreakerHalfOpen
+		b.probing = true
+
+		return nil
+	case BreakerHalfOpen:
+		if b.probing {
+			return &CircuitOpenError{RetryAfter: b.cooldown}
+		}
+
+		b.probing = true
+
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (b *breaker) Success() {
+	if b.threshold == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = BreakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *breaker) Failure() {
+	if b.threshold == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		// open the breaker until the cool down elapses
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *breaker) Abort() {
+	if b.threshold == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// let another request probe authentik
+	b.probing = false
+}
+
+func (b *breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.cooldown {
+		return BreakerHalfOpen
+	}
+
+	return b.state
+}
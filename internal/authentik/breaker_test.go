@@ -0,0 +1,173 @@
+package authentik_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/authentik"
+)
+
+func TestBreaker(t *testing.T) {
+	t.Run("open after consecutive failures", func(t *testing.T) {
+		var akCalls atomic.Int32
+		server := newCountingServer(t, &akCalls, http.StatusBadGateway)
+
+		config := &authentik.Config{
+			Addresses:        []string{server.URL},
+			BreakerThreshold: 2,
+			BreakerCooldown:  time.Minute,
+		}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		meta := &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{},
+		}
+
+		for i := 0; i < 2; i++ {
+			if _, err := client.Check(meta); err == nil {
+				t.Fatalf("expected error, got none")
+			}
+		}
+
+		// check that the breaker is open
+		if client.BreakerState() != authentik.BreakerOpen {
+			t.Fatalf("expected breaker to be %s, got %s", authentik.BreakerOpen, client.BreakerState())
+		}
+
+		_, err := client.Check(meta)
+
+		// check that the request failed fast
+		if !errors.Is(err, authentik.ErrCircuitOpen) {
+			t.Fatalf("expected error %q, got %q", authentik.ErrCircuitOpen, err)
+		}
+
+		var circuitErr *authentik.CircuitOpenError
+		if !errors.As(err, &circuitErr) || circuitErr.RetryAfter <= 0 {
+			t.Errorf("expected error to carry a positive retry after")
+		}
+
+		// check that the authentik server was not called while open
+		expectedCalls := int32(2)
+		if akCalls.Load() != expectedCalls {
+			t.Errorf("expected %d authentik calls, got %d", expectedCalls, akCalls.Load())
+		}
+	})
+
+	t.Run("close after successful probe", func(t *testing.T) {
+		var failing atomic.Bool
+		failing.Store(true)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if failing.Load() {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+
+		config := &authentik.Config{
+			Addresses:        []string{server.URL},
+			BreakerThreshold: 1,
+			BreakerCooldown:  10 * time.Millisecond,
+		}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		meta := &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{},
+		}
+
+		if _, err := client.Check(meta); err == nil {
+			t.Fatalf("expected error, got none")
+		}
+
+		// wait for the cool down to elapse
+		failing.Store(false)
+		time.Sleep(30 * time.Millisecond)
+
+		// check that the breaker is half open
+		if client.BreakerState() != authentik.BreakerHalfOpen {
+			t.Fatalf("expected breaker to be %s, got %s", authentik.BreakerHalfOpen, client.BreakerState())
+		}
+
+		if _, err := client.Check(meta); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// check that the breaker is closed
+		if client.BreakerState() != authentik.BreakerClosed {
+			t.Errorf("expected breaker to be %s, got %s", authentik.BreakerClosed, client.BreakerState())
+		}
+	})
+
+	t.Run("reopen after failed probe", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer server.Close()
+
+		config := &authentik.Config{
+			Addresses:        []string{server.URL},
+			BreakerThreshold: 1,
+			BreakerCooldown:  10 * time.Millisecond,
+		}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		meta := &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{},
+		}
+
+		if _, err := client.Check(meta); err == nil {
+			t.Fatalf("expected error, got none")
+		}
+
+		// wait for the cool down to elapse
+		time.Sleep(30 * time.Millisecond)
+
+		_, err := client.Check(meta)
+		if err == nil || errors.Is(err, authentik.ErrCircuitOpen) {
+			t.Fatalf("expected probe error, got %v", err)
+		}
+
+		// check that the breaker is open again
+		if client.BreakerState() != authentik.BreakerOpen {
+			t.Errorf("expected breaker to be %s, got %s", authentik.BreakerOpen, client.BreakerState())
+		}
+	})
+
+	t.Run("disabled breaker", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer server.Close()
+
+		config := &authentik.Config{Addresses: []string{server.URL}}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		meta := &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{},
+		}
+
+		for i := 0; i < 5; i++ {
+			_, err := client.Check(meta)
+			if errors.Is(err, authentik.ErrCircuitOpen) {
+				t.Fatalf("expected breaker to be disabled")
+			}
+		}
+
+		// check that the breaker is closed
+		if client.BreakerState() != authentik.BreakerClosed {
+			t.Errorf("expected breaker to be %s, got %s", authentik.BreakerClosed, client.BreakerState())
+		}
+	})
+}
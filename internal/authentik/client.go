@@ -2,31 +2,99 @@ package authentik
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/session"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/tracing"
 )
 
 type Client struct {
 	config  *Config
 	client  *http.Client
 	session session.Client
+	flight  *flightGroup
+	breaker *breaker
+	pool    *endpointPool
+	metrics *clientMetrics
+	tracer  *tracing.Tracer
 }
 
 func NewClient(context context.Context, client *http.Client, config *Config) *Client {
-	return &Client{
-		config:  config,
-		client:  client,
-		session: session.NewClient(context, config.CacheDuration),
+	sessionConfig := newSessionConfig(config)
+
+	c := &Client{
+		config: config,
+		client: client,
+		breaker: &breaker{
+			threshold: config.BreakerThreshold,
+			cooldown:  config.BreakerCooldown,
+		},
+		pool:    newEndpointPool(config.Addresses, config.AddressStrategy, config.AddressCooldown),
+		metrics: newClientMetrics(),
+	}
+
+	if config.Tracing != nil {
+		c.tracer = tracing.New(context, config.Tracing)
 	}
+
+	if config.CacheShared {
+		// share the cache with other instances with identical settings
+		shared := registry.Acquire(context, config, sessionConfig)
+
+		c.session = shared.session
+		c.flight = shared.flight
+	} else {
+		c.session = session.NewClient(context, sessionConfig)
+		c.flight = &flightGroup{}
+	}
+
+	return c
+}
+
+func newSessionConfig(config *Config) *session.Config {
+	return &session.Config{
+		Duration:      max(config.CacheDuration, config.CacheNegativeDuration),
+		StaleDuration: config.CacheStaleIfError,
+		MaxEntries:    config.CacheMaxEntries,
+		MaxBytes:      config.CacheMaxBytes,
+
+		SnapshotFile:     config.CacheSnapshotFile,
+		SnapshotInterval: config.CacheSnapshotInterval,
+
+		Backend:       config.CacheBackend,
+		Redis:         config.CacheRedis,
+		Prefix:        config.CacheRedisPrefix,
+		EncryptionKey: config.CacheEncryptionKey,
+	}
+}
+
+func (c *Client) BreakerState() BreakerState {
+	return c.breaker.State()
 }
 
 func (c *Client) Check(meta *RequestMeta) (*ResponseMeta, error) {
+	sessionKey := c.getSessionKey(meta)
+
 	// check if s is already cached
-	if s := c.session.Get(meta.Cookies); s != nil {
+	if s, expires := c.session.Get(sessionKey); s != nil {
+		c.metrics.cache.Inc(CacheHit)
+		c.metrics.checks.Inc(getOutcome(s.IsAuthenticated))
+
+		if c.config.CacheRevalidateWindow > 0 && time.Until(expires) <= c.config.CacheRevalidateWindow {
+			// refresh session in background while serving the cached one
+			c.flight.Go(sessionKey, func() (*session.Session, error) {
+				bgMeta, cancel := c.detach(meta)
+				defer cancel()
+
+				return c.check(bgMeta)
+			})
+		}
+
 		return &ResponseMeta{
 			URL:     meta.URL,
 			Cached:  true,
@@ -34,51 +102,211 @@ func (c *Client) Check(meta *RequestMeta) (*ResponseMeta, error) {
 		}, nil
 	}
 
+	var s *session.Session
+	var coalesced bool
+	var err error
+
+	if sessionKey == "" {
+		c.metrics.cache.Inc(CacheBypass)
+
+		// anonymous requests are never coalesced
+		s, err = c.check(meta)
+	} else {
+		c.metrics.cache.Inc(CacheMiss)
+
+		// coalesce concurrent checks for the same session, running the shared
+		// check independently of the request that started it
+		s, coalesced, err = c.flight.Do(getContext(meta), sessionKey, func() (*session.Session, error) {
+			bgMeta, cancel := c.detach(meta)
+			defer cancel()
+
+			return c.check(bgMeta)
+		})
+	}
+
+	if err != nil {
+		// serve last known authenticated session if authentik is failing
+		if s := c.session.GetStale(sessionKey); s != nil && s.IsAuthenticated {
+			c.metrics.cache.Inc(CacheStale)
+			c.metrics.checks.Inc(OutcomeStale)
+
+			return &ResponseMeta{
+				URL:     meta.URL,
+				Cached:  true,
+				Stale:   true,
+				Session: s,
+			}, nil
+		}
+
+		c.metrics.checks.Inc(OutcomeError)
+
+		return nil, err
+	}
+
+	c.metrics.checks.Inc(getOutcome(s.IsAuthenticated))
+
+	return &ResponseMeta{
+		URL:       meta.URL,
+		Cached:    false,
+		Coalesced: coalesced,
+		Session:   s,
+	}, nil
+}
+
+// detach returns a copy of the request metadata whose context is not canceled
+// with the downstream request, bounded by the http client timeout instead.
+func (c *Client) detach(meta *RequestMeta) (*RequestMeta, context.CancelFunc) {
+	ctx := context.WithoutCancel(getContext(meta))
+	cancel := context.CancelFunc(func() {})
+
+	if c.client.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.client.Timeout)
+	}
+
+	bgMeta := *meta
+	bgMeta.Context = ctx
+
+	return &bgMeta, cancel
+}
+
+func (c *Client) check(meta *RequestMeta) (*session.Session, error) {
+	meta, span := c.startSpan(meta, "authentik check", NginxPath)
+	defer span.End()
+
 	// send request to authentik to check if request is authenticated
-	res, err := c.request(meta, NginxPath, "")
+	res, err := c.requestWithRetry(meta, NginxPath, "")
 	if err != nil {
+		span.SetError(err)
 		return nil, err
 	}
 	defer func() { _ = res.Body.Close() }()
 
+	span.SetInt("http.response.status_code", res.StatusCode)
+
 	var s *session.Session
+	var ttl time.Duration
 	switch res.StatusCode {
 	case http.StatusUnauthorized:
 		s = &session.Session{
 			IsAuthenticated: false,
+			Host:            meta.URL.Host,
 			Headers:         nil,
 			Cookies:         GetCookies(res),
 		}
+		ttl = c.config.CacheNegativeDuration
 	case http.StatusOK:
 		s = &session.Session{
 			IsAuthenticated: true,
+			Host:            meta.URL.Host,
 			Headers:         GetHeaders(res),
 			Cookies:         GetCookies(res),
 		}
+		ttl = c.config.CacheDuration
 	default:
-		return nil, fmt.Errorf("unexpected response: %d", res.StatusCode)
+		err := fmt.Errorf("unexpected response: %d", res.StatusCode)
+		span.SetError(err)
+		return nil, err
+	}
+
+	span.SetBool("authentik.authenticated", s.IsAuthenticated)
+
+	if c.config.CacheRespectHeaders {
+		// never cache the session longer than authentik allows
+		if lifetime, ok := GetCacheLifetime(res); ok {
+			ttl = min(ttl, lifetime)
+		}
 	}
 
 	// cache session
-	c.session.Set(meta.Cookies, s)
+	c.session.Set(c.getSessionKey(meta), s, ttl)
 
-	return &ResponseMeta{
-		URL:     meta.URL,
-		Cached:  false,
-		Session: s,
-	}, nil
+	return s, nil
+}
+
+// getSessionKey returns the cache key of the request session, partitioned by
+// host, as authentik resolves the application serving a request by its host.
+func (c *Client) getSessionKey(meta *RequestMeta) string {
+	return session.GetIdentifier(meta.Cookies, meta.URL.Host)
 }
 
 func (c *Client) Request(meta *RequestMeta, path string, query string) (*http.Response, error) {
+	sessionKey := c.getSessionKey(meta)
+
+	if path == SignOutPath {
+		// delete every cached session of the signed out user
+		c.deleteUserSessions(sessionKey, meta.URL.Host)
+	}
+
 	// delete session if already cached
-	c.session.Delete(meta.Cookies)
+	c.session.Delete(sessionKey)
 
-	return c.request(meta, path, query)
+	meta, span := c.startSpan(meta, "authentik outpost", path)
+	defer span.End()
+
+	res, err := c.request(meta, path, query)
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+
+	span.SetInt("http.response.status_code", res.StatusCode)
+
+	return res, nil
+}
+
+// startSpan starts a child span of the downstream request, returning a copy of
+// the request metadata that propagates the span to authentik.
+func (c *Client) startSpan(meta *RequestMeta, name string, path string) (*RequestMeta, *tracing.Span) {
+	span := c.tracer.Start(name, meta.Traceparent)
+	if span == nil {
+		return meta, nil
+	}
+
+	span.SetString("server.address", meta.URL.Host)
+	span.SetString("url.path", path)
+
+	child := *meta
+	child.Traceparent = span.Traceparent()
+
+	return &child, span
+}
+
+func (c *Client) deleteUserSessions(sessionKey string, host string) {
+	if c.config.CacheSignOutScope != SignOutScopeHost && c.config.CacheSignOutScope != SignOutScopeUser {
+		return
+	}
+
+	if c.config.CacheSignOutScope == SignOutScopeUser {
+		// delete sessions on every host
+		host = ""
+	}
+
+	if s := c.session.GetStale(sessionKey); s != nil {
+		for _, user := range s.GetUsers() {
+			c.session.DeleteUser(user, host)
+		}
+	}
+}
+
+// Revoke deletes the cached session of a request, along with every other
+// cached session of the same user.
+func (c *Client) Revoke(meta *RequestMeta, s *session.Session) {
+	c.session.Delete(c.getSessionKey(meta))
+	c.DeleteUsers(s.GetUsers())
+}
+
+// DeleteUsers deletes every cached session of the given user identifiers.
+func (c *Client) DeleteUsers(users []string) {
+	for _, user := range users {
+		c.session.DeleteUser(user, "")
+	}
 }
 
 func (c *Client) request(meta *RequestMeta, path string, query string) (*http.Response, error) {
-	// send request to authentik
-	akReq, err := http.NewRequest(http.MethodGet, c.config.Address+path, nil)
+	// send request to the next available authentik address
+	endpoint := c.pool.Pick()
+
+	akReq, err := http.NewRequestWithContext(getContext(meta), http.MethodGet, endpoint.address+path, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -89,16 +317,49 @@ func (c *Client) request(meta *RequestMeta, path string, query string) (*http.Re
 	akReq.Header.Set("X-Forwarded-Host", meta.URL.Host)
 	akReq.Header.Set("X-Original-Uri", meta.URL.String())
 
+	// propagate downstream trace context
+	if meta.Traceparent != "" {
+		akReq.Header.Set(tracing.TraceparentHeader, meta.Traceparent)
+
+		if meta.Tracestate != "" {
+			akReq.Header.Set(tracing.TracestateHeader, meta.Tracestate)
+		}
+	}
+
 	// add downstream authentik session cookies
 	for _, c := range meta.Cookies {
 		akReq.AddCookie(c)
 	}
 
+	// fail fast while authentik is known to be unavailable
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
 	res, err := c.client.Do(akReq)
+	c.metrics.latency.Observe(time.Since(start).Seconds())
+
 	if err != nil {
+		if errors.Is(akReq.Context().Err(), context.Canceled) {
+			// downstream cancellations are not authentik failures, timeouts are
+			c.breaker.Abort()
+		} else {
+			c.breaker.Failure()
+			c.pool.MarkDown(endpoint)
+		}
+
 		return nil, err
 	}
 
+	if res.StatusCode >= http.StatusInternalServerError {
+		c.breaker.Failure()
+		c.pool.MarkDown(endpoint)
+	} else {
+		c.breaker.Success()
+		c.pool.MarkUp(endpoint)
+	}
+
 	if err := c.mangleLocation(meta, res); err != nil {
 		return nil, err
 	}
@@ -115,9 +376,9 @@ func (c *Client) mangleLocation(meta *RequestMeta, res *http.Response) error {
 		return nil
 	}
 
-	if strings.HasPrefix(loc, c.config.Address+BasePath) {
-		// convert absolute outpost redirects to downstream host
-		loc = strings.TrimPrefix(loc, c.config.Address)
+	if address, ok := c.pool.Match(loc); ok {
+		// convert absolute outpost redirects from any address to downstream host
+		loc = strings.TrimPrefix(loc, address)
 
 		locURL, err := url.Parse(loc)
 		if err != nil {
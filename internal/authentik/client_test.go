@@ -2,12 +2,20 @@ package authentik_test
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/authentik"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/tracing"
 )
 
 func TestCheck(t *testing.T) {
@@ -33,7 +41,7 @@ func TestCheck(t *testing.T) {
 		}))
 		defer server.Close()
 
-		config := &authentik.Config{Address: server.URL}
+		config := &authentik.Config{Addresses: []string{server.URL}}
 		client := authentik.NewClient(context.Background(), server.Client(), config)
 
 		reqMeta := &authentik.RequestMeta{
@@ -109,7 +117,7 @@ func TestCheck(t *testing.T) {
 		}))
 		defer server.Close()
 
-		config := &authentik.Config{Address: server.URL}
+		config := &authentik.Config{Addresses: []string{server.URL}}
 		client := authentik.NewClient(context.Background(), server.Client(), config)
 
 		reqMeta := &authentik.RequestMeta{
@@ -177,7 +185,7 @@ func TestCheck(t *testing.T) {
 		}))
 		defer server.Close()
 
-		config := &authentik.Config{Address: server.URL}
+		config := &authentik.Config{Addresses: []string{server.URL}}
 		client := authentik.NewClient(context.Background(), server.Client(), config)
 
 		meta := &authentik.RequestMeta{
@@ -256,7 +264,7 @@ func TestRequest(t *testing.T) {
 		}))
 		defer akServer.Close()
 
-		config := &authentik.Config{Address: akServer.URL}
+		config := &authentik.Config{Addresses: []string{akServer.URL}}
 		client := authentik.NewClient(context.Background(), akServer.Client(), config)
 
 		meta := &authentik.RequestMeta{
@@ -300,7 +308,7 @@ func TestRequest(t *testing.T) {
 		}
 		defer akServer.Close()
 
-		config := &authentik.Config{Address: akServer.URL}
+		config := &authentik.Config{Addresses: []string{akServer.URL}}
 		client := authentik.NewClient(context.Background(), akServer.Client(), config)
 
 		meta := &authentik.RequestMeta{
@@ -338,7 +346,7 @@ func TestRequest(t *testing.T) {
 		}))
 		defer akServer.Close()
 
-		config := &authentik.Config{Address: akServer.URL}
+		config := &authentik.Config{Addresses: []string{akServer.URL}}
 		client := authentik.NewClient(context.Background(), akServer.Client(), config)
 
 		meta := &authentik.RequestMeta{
@@ -372,3 +380,780 @@ func TestRequest(t *testing.T) {
 		}
 	})
 }
+
+func TestCheck_Cache(t *testing.T) {
+	t.Run("with unauthenticated response and no negative duration", func(t *testing.T) {
+		akCalls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			akCalls++
+
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+
+		config := &authentik.Config{
+			Addresses:             []string{server.URL},
+			CacheDuration:         time.Minute,
+			CacheNegativeDuration: 0,
+		}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		meta := &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{{Name: "authentik_proxy_session", Value: "test-session"}},
+		}
+
+		for i := 0; i < 2; i++ {
+			resMeta, err := client.Check(meta)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// check that the response was not cached
+			if resMeta.Cached {
+				t.Errorf("expected response not to be cached")
+			}
+		}
+
+		// check that the authentik server was called for every check
+		expectedCalls := 2
+		if akCalls != expectedCalls {
+			t.Errorf("expected %d authentik calls, got %d", expectedCalls, akCalls)
+		}
+	})
+
+	t.Run("with authenticated response and no negative duration", func(t *testing.T) {
+		akCalls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			akCalls++
+
+			w.Header().Set("X-Authentik-User", "testuser")
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		config := &authentik.Config{
+			Addresses:             []string{server.URL},
+			CacheDuration:         time.Minute,
+			CacheNegativeDuration: 0,
+		}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		meta := &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{{Name: "authentik_proxy_session", Value: "test-session"}},
+		}
+
+		if _, err := client.Check(meta); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		resMeta, err := client.Check(meta)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// check that the second response was cached
+		if !resMeta.Cached {
+			t.Errorf("expected response to be cached")
+		}
+
+		// check that the authentik server was called once
+		expectedCalls := 1
+		if akCalls != expectedCalls {
+			t.Errorf("expected %d authentik calls, got %d", expectedCalls, akCalls)
+		}
+	})
+}
+
+func TestCheck_Coalesce(t *testing.T) {
+	t.Run("with concurrent checks for the same session", func(t *testing.T) {
+		var akCalls atomic.Int32
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			akCalls.Add(1)
+
+			// hold the response until all checks are in flight
+			<-release
+
+			w.Header().Set("X-Authentik-User", "testuser")
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		config := &authentik.Config{Addresses: []string{server.URL}}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		meta := &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{{Name: "authentik_proxy_session", Value: "test-session"}},
+		}
+
+		checks := 10
+		results := make(chan *authentik.ResponseMeta, checks)
+
+		var wg sync.WaitGroup
+		for i := 0; i < checks; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				resMeta, err := client.Check(meta)
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+
+				results <- resMeta
+			}()
+		}
+
+		// give every check time to join the in flight request
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+
+		wg.Wait()
+		close(results)
+
+		// check that the authentik server was called once
+		expectedCalls := int32(1)
+		if akCalls.Load() != expectedCalls {
+			t.Errorf("expected %d authentik calls, got %d", expectedCalls, akCalls.Load())
+		}
+
+		// check that every other result was coalesced
+		coalesced := 0
+		for resMeta := range results {
+			if !resMeta.Session.IsAuthenticated {
+				t.Errorf("expected request to be authenticated")
+			}
+
+			if resMeta.Coalesced {
+				coalesced++
+			}
+		}
+
+		expectedCoalesced := checks - 1
+		if coalesced != expectedCoalesced {
+			t.Errorf("expected %d coalesced results, got %d", expectedCoalesced, coalesced)
+		}
+	})
+}
+
+func TestCheck_Revalidate(t *testing.T) {
+	t.Run("with cached session inside revalidate window", func(t *testing.T) {
+		var akCalls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls := akCalls.Add(1)
+
+			w.Header().Set("X-Authentik-User", "testuser"+strconv.Itoa(int(calls)))
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		config := &authentik.Config{
+			Addresses:             []string{server.URL},
+			CacheDuration:         time.Minute,
+			CacheRevalidateWindow: time.Minute,
+		}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		meta := &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{{Name: "authentik_proxy_session", Value: "test-session"}},
+		}
+
+		if _, err := client.Check(meta); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		resMeta, err := client.Check(meta)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// check that the cached session was served
+		if !resMeta.Cached {
+			t.Errorf("expected response to be cached")
+		}
+
+		expectedUser := "testuser1"
+		if resMeta.Session.Headers.Get("X-Authentik-User") != expectedUser {
+			t.Errorf("expected X-Authentik-User to be %s, got %s", expectedUser, resMeta.Session.Headers.Get("X-Authentik-User"))
+		}
+
+		// wait for the background refresh to finish
+		time.Sleep(50 * time.Millisecond)
+
+		// check that the authentik server was called again
+		expectedCalls := int32(2)
+		if akCalls.Load() != expectedCalls {
+			t.Errorf("expected %d authentik calls, got %d", expectedCalls, akCalls.Load())
+		}
+
+		// check that the refreshed session replaced the cached one
+		resMeta, err = client.Check(meta)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expectedUser = "testuser2"
+		if resMeta.Session.Headers.Get("X-Authentik-User") != expectedUser {
+			t.Errorf("expected X-Authentik-User to be %s, got %s", expectedUser, resMeta.Session.Headers.Get("X-Authentik-User"))
+		}
+	})
+
+	t.Run("with cached session outside revalidate window", func(t *testing.T) {
+		var akCalls atomic.Int32
+		server := newCountingServer(t, &akCalls, http.StatusOK)
+
+		config := &authentik.Config{
+			Addresses:             []string{server.URL},
+			CacheDuration:         time.Minute,
+			CacheRevalidateWindow: time.Second,
+		}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		meta := &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{{Name: "authentik_proxy_session", Value: "test-session"}},
+		}
+
+		for i := 0; i < 2; i++ {
+			if _, err := client.Check(meta); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		// wait for any background refresh to finish
+		time.Sleep(50 * time.Millisecond)
+
+		// check that the authentik server was called once
+		expectedCalls := int32(1)
+		if akCalls.Load() != expectedCalls {
+			t.Errorf("expected %d authentik calls, got %d", expectedCalls, akCalls.Load())
+		}
+	})
+}
+
+func TestCheck_StaleIfError(t *testing.T) {
+	t.Run("with expired authenticated session and server error", func(t *testing.T) {
+		var akCalls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if akCalls.Add(1) > 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+
+			w.Header().Set("X-Authentik-User", "testuser")
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		config := &authentik.Config{
+			Addresses:         []string{server.URL},
+			CacheDuration:     10 * time.Millisecond,
+			CacheStaleIfError: time.Minute,
+		}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		meta := &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{{Name: "authentik_proxy_session", Value: "test-session"}},
+		}
+
+		if _, err := client.Check(meta); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// wait for the session to expire
+		time.Sleep(30 * time.Millisecond)
+
+		resMeta, err := client.Check(meta)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// check that the stale session was served
+		if !resMeta.Stale {
+			t.Errorf("expected response to be stale")
+		}
+
+		if !resMeta.Session.IsAuthenticated {
+			t.Errorf("expected request to be authenticated")
+		}
+
+		expectedUser := "testuser"
+		if resMeta.Session.Headers.Get("X-Authentik-User") != expectedUser {
+			t.Errorf("expected X-Authentik-User to be %s, got %s", expectedUser, resMeta.Session.Headers.Get("X-Authentik-User"))
+		}
+	})
+
+	t.Run("with expired unauthenticated session and server error", func(t *testing.T) {
+		var akCalls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if akCalls.Add(1) > 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+
+		config := &authentik.Config{
+			Addresses:             []string{server.URL},
+			CacheDuration:         10 * time.Millisecond,
+			CacheNegativeDuration: 10 * time.Millisecond,
+			CacheStaleIfError:     time.Minute,
+		}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		meta := &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{{Name: "authentik_proxy_session", Value: "test-session"}},
+		}
+
+		if _, err := client.Check(meta); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// wait for the session to expire
+		time.Sleep(30 * time.Millisecond)
+
+		// check that unauthenticated sessions are never served as stale
+		if _, err := client.Check(meta); err == nil {
+			t.Fatalf("expected error, got none")
+		}
+	})
+}
+
+func TestCheck_RespectHeaders(t *testing.T) {
+	tests := []struct {
+		name          string
+		cacheControl  string
+		expectedCalls int
+	}{
+		{
+			name:          "with no store response",
+			cacheControl:  "no-store",
+			expectedCalls: 2,
+		},
+		{
+			name:          "with cacheable response",
+			cacheControl:  "max-age=60",
+			expectedCalls: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			akCalls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				akCalls++
+
+				w.Header().Set("Cache-Control", test.cacheControl)
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			config := &authentik.Config{
+				Addresses:           []string{server.URL},
+				CacheDuration:       time.Minute,
+				CacheRespectHeaders: true,
+			}
+			client := authentik.NewClient(context.Background(), server.Client(), config)
+
+			meta := &authentik.RequestMeta{
+				URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+				Cookies: []*http.Cookie{{Name: "authentik_proxy_session", Value: "test-session"}},
+			}
+
+			for i := 0; i < 2; i++ {
+				if _, err := client.Check(meta); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			// check that the authentik server was called the expected times
+			if akCalls != test.expectedCalls {
+				t.Errorf("expected %d authentik calls, got %d", test.expectedCalls, akCalls)
+			}
+		})
+	}
+}
+
+func TestCheck_Partition(t *testing.T) {
+	t.Run("with same session on different hosts", func(t *testing.T) {
+		akCalls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			akCalls++
+
+			w.Header().Set("X-Authentik-Meta-App", r.Header.Get("X-Forwarded-Host"))
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		config := &authentik.Config{
+			Addresses:     []string{server.URL},
+			CacheDuration: time.Minute,
+		}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		cookies := []*http.Cookie{{Name: "authentik_proxy_session", Value: "test-session"}}
+
+		for _, host := range []string{"first.example.com", "second.example.com", "first.example.com"} {
+			meta := &authentik.RequestMeta{
+				URL:     &url.URL{Scheme: "https", Host: host, Path: "/protected"},
+				Cookies: cookies,
+			}
+
+			resMeta, err := client.Check(meta)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// check that the session belongs to the requested host
+			if resMeta.Session.Headers.Get("X-Authentik-Meta-App") != host {
+				t.Errorf("expected session for host %s, got %s", host, resMeta.Session.Headers.Get("X-Authentik-Meta-App"))
+			}
+		}
+
+		// check that every host was checked once
+		expectedCalls := 2
+		if akCalls != expectedCalls {
+			t.Errorf("expected %d authentik calls, got %d", expectedCalls, akCalls)
+		}
+	})
+}
+
+func TestCheck_Anonymous(t *testing.T) {
+	t.Run("with concurrent anonymous clients", func(t *testing.T) {
+		akCalls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			akCalls++
+
+			http.SetCookie(w, &http.Cookie{
+				Name:  "authentik_proxy_state",
+				Value: "state" + strconv.Itoa(akCalls),
+			})
+
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+
+		config := &authentik.Config{
+			Addresses:     []string{server.URL},
+			CacheDuration: time.Minute,
+		}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		for i := 1; i <= 2; i++ {
+			meta := &authentik.RequestMeta{
+				URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+				Cookies: []*http.Cookie{},
+			}
+
+			resMeta, err := client.Check(meta)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// check that the response was not served from the cache
+			if resMeta.Cached {
+				t.Errorf("expected response not to be cached")
+			}
+
+			// check that every client receives its own state cookie
+			expectedValue := "state" + strconv.Itoa(i)
+			if len(resMeta.Session.Cookies) != 1 || resMeta.Session.Cookies[0].Value != expectedValue {
+				t.Errorf("expected state cookie %s, got %v", expectedValue, resMeta.Session.Cookies)
+			}
+		}
+	})
+}
+
+func TestRequest_SignOut(t *testing.T) {
+	newMeta := func(host string, value string) *authentik.RequestMeta {
+		return &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: host, Path: "/protected"},
+			Cookies: []*http.Cookie{{Name: "authentik_proxy_session", Value: value}},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		scope         string
+		expectedCache map[string]bool
+	}{
+		{
+			name:  "with session scope",
+			scope: authentik.SignOutScopeSession,
+			expectedCache: map[string]bool{
+				"first":  false,
+				"second": true,
+				"other":  true,
+			},
+		},
+		{
+			name:  "with host scope",
+			scope: authentik.SignOutScopeHost,
+			expectedCache: map[string]bool{
+				"first":  false,
+				"second": false,
+				"other":  true,
+			},
+		},
+		{
+			name:  "with user scope",
+			scope: authentik.SignOutScopeUser,
+			expectedCache: map[string]bool{
+				"first":  false,
+				"second": false,
+				"other":  false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Authentik-Uid", "user")
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			config := &authentik.Config{
+				Addresses:         []string{server.URL},
+				CacheDuration:     time.Minute,
+				CacheSignOutScope: tt.scope,
+			}
+			client := authentik.NewClient(context.Background(), server.Client(), config)
+
+			// the same user is signed in from two browsers and on two hosts
+			metas := map[string]*authentik.RequestMeta{
+				"first":  newMeta("a.example.com", "first"),
+				"second": newMeta("a.example.com", "second"),
+				"other":  newMeta("b.example.com", "first"),
+			}
+
+			for _, meta := range metas {
+				if _, err := client.Check(meta); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			res, err := client.Request(metas["first"], authentik.SignOutPath, "")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_ = res.Body.Close()
+
+			for name, expected := range tt.expectedCache {
+				resMeta, err := client.Check(metas[name])
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if resMeta.Cached != expected {
+					t.Errorf("expected %s session cached to be %t, got %t", name, expected, resMeta.Cached)
+				}
+			}
+		})
+	}
+}
+
+func TestCheck_Trace(t *testing.T) {
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	t.Run("without tracing", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// check that the downstream trace context is forwarded as is
+			if r.Header.Get("Traceparent") != parent {
+				t.Errorf("expected traceparent %s, got %s", parent, r.Header.Get("Traceparent"))
+			}
+
+			if r.Header.Get("Tracestate") != "vendor=value" {
+				t.Errorf("expected tracestate vendor=value, got %s", r.Header.Get("Tracestate"))
+			}
+
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+
+		config := &authentik.Config{Addresses: []string{server.URL}}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		reqMeta := &authentik.RequestMeta{
+			URL:         &url.URL{Scheme: "https", Host: "example.com", Path: "/"},
+			Cookies:     []*http.Cookie{},
+			Traceparent: parent,
+			Tracestate:  "vendor=value",
+		}
+
+		if _, err := client.Check(reqMeta); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("with tracing", func(t *testing.T) {
+		exported := make(chan string, 1)
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			exported <- string(body)
+		}))
+		defer collector.Close()
+
+		var received string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r.Header.Get("Traceparent")
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		config := &authentik.Config{
+			Addresses: []string{server.URL},
+			Tracing: &tracing.Config{
+				Endpoint:    collector.URL,
+				ServiceName: "test-service",
+				Interval:    10 * time.Millisecond,
+				Timeout:     time.Second,
+			},
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		client := authentik.NewClient(ctx, server.Client(), config)
+
+		reqMeta := &authentik.RequestMeta{
+			URL:         &url.URL{Scheme: "https", Host: "example.com", Path: "/"},
+			Cookies:     []*http.Cookie{},
+			Traceparent: parent,
+		}
+
+		if _, err := client.Check(reqMeta); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// check that authentik receives a child span of the downstream trace
+		if !strings.HasPrefix(received, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || received == parent {
+			t.Fatalf("expected child traceparent, got %s", received)
+		}
+
+		select {
+		case body := <-exported:
+			spanID := strings.Split(received, "-")[2]
+
+			for _, expected := range []string{`"spanId":"` + spanID + `"`, `"parentSpanId":"00f067aa0ba902b7"`, `"name":"authentik check"`} {
+				if !strings.Contains(body, expected) {
+					t.Errorf("expected exported span to contain %s, got %s", expected, body)
+				}
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected span to be exported")
+		}
+	})
+}
+
+func TestCheck_CoalesceCancel(t *testing.T) {
+	t.Run("with first caller canceled while another is waiting", func(t *testing.T) {
+		var akCalls atomic.Int32
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			akCalls.Add(1)
+
+			// hold the response until the first caller is canceled
+			<-release
+
+			w.Header().Set("X-Authentik-User", "testuser")
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		config := &authentik.Config{Addresses: []string{server.URL}}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		newMeta := func(ctx context.Context) *authentik.RequestMeta {
+			return &authentik.RequestMeta{
+				Context: ctx,
+				URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+				Cookies: []*http.Cookie{{Name: "authentik_proxy_session", Value: "test-session"}},
+			}
+		}
+
+		firstCtx, cancelFirst := context.WithCancel(context.Background())
+
+		firstErr := make(chan error, 1)
+		go func() {
+			_, err := client.Check(newMeta(firstCtx))
+			firstErr <- err
+		}()
+
+		// wait for the first check to reach authentik before joining it
+		for akCalls.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		second := make(chan *authentik.ResponseMeta, 1)
+		secondErr := make(chan error, 1)
+		go func() {
+			resMeta, err := client.Check(newMeta(context.Background()))
+			second <- resMeta
+			secondErr <- err
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+
+		// disconnect the first caller while the second is waiting
+		cancelFirst()
+
+		if err := <-firstErr; !errors.Is(err, context.Canceled) {
+			t.Errorf("expected first check to be canceled, got %v", err)
+		}
+
+		close(release)
+
+		resMeta := <-second
+		if err := <-secondErr; err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// check that the waiting caller got the shared result
+		if !resMeta.Session.IsAuthenticated {
+			t.Error("expected request to be authenticated")
+		}
+
+		if !resMeta.Coalesced {
+			t.Error("expected result to be coalesced")
+		}
+
+		expectedCalls := int32(1)
+		if akCalls.Load() != expectedCalls {
+			t.Errorf("expected %d authentik calls, got %d", expectedCalls, akCalls.Load())
+		}
+	})
+}
+
+// newCountingServer starts a fake authentik server answering every request
+// with status, counting the requests in akCalls.
+func newCountingServer(t *testing.T, akCalls *atomic.Int32, status int) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		akCalls.Add(1)
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// newSessionMeta returns the metadata of a request carrying a session cookie.
+func newSessionMeta() *authentik.RequestMeta {
+	return &authentik.RequestMeta{
+		URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+		Cookies: []*http.Cookie{{Name: "authentik_proxy_session", Value: "test-session"}},
+	}
+}
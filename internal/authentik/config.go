@@ -1,68 +1,89 @@
 package authentik
 
 import (
-	"net/http"
-	"regexp"
+	"strings"
 	"time"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/httpclient"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/redis"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/tracing"
+)
+
+const (
+	SignOutScopeSession = "session"
+	SignOutScopeHost    = "host"
+	SignOutScopeUser    = "user"
+)
+
+const (
+	GroupMatchAny = "any"
+	GroupMatchAll = "all"
 )
 
 type Config struct {
-	Address       string
-	CacheDuration time.Duration
+	Addresses             []string
+	AddressStrategy       string
+	AddressCooldown       time.Duration
+	CacheDuration         time.Duration
+	CacheNegativeDuration time.Duration
+	CacheRevalidateWindow time.Duration
+	CacheStaleIfError     time.Duration
+	CacheRespectHeaders   bool
+	CacheMaxEntries       int
+	CacheMaxBytes         int64
+	CacheShared           bool
+	CacheSignOutScope     string
+
+	CacheSnapshotFile     string
+	CacheSnapshotInterval time.Duration
+
+	CacheBackend       string
+	CacheRedis         *redis.Config
+	CacheRedisPrefix   string
+	CacheEncryptionKey string
+
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	RetryAttempts   int
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+
+	WebhookPath   string
+	WebhookSecret string
+
+	Tracing *tracing.Config
+
+	// settings of the http client used to reach authentik
+	HTTPClient *httpclient.Config
 
 	UnauthorizedStatusCode int
 	RedirectStatusCode     int
+	ForbiddenStatusCode    int
 
-	SkippedPaths      []*regexp.Regexp
-	UnauthorizedPaths []*regexp.Regexp
-	RedirectPaths     []*regexp.Regexp
-}
-
-func (c *Config) IsSkippedPath(path string) bool {
-	// check if request path matches any of the skipped paths
-	for _, p := range c.SkippedPaths {
-		if p.MatchString(path) {
-			return true
-		}
-	}
+	Rules []*Rule
 
-	return false
+	CORSPreflight      bool
+	CORSAllowedOrigins []string
 }
 
-func (c *Config) GetUnauthorizedStatusCode(path string) int {
-	var longestMatch *regexp.Regexp
-	var longestMatchLength int
-	var longestMatchStatusCode int
-
-	// check if request path matches any of the unauthorized paths
-	for _, re := range c.UnauthorizedPaths {
-		if re.MatchString(path) {
-			l := len(re.String())
-			if l > longestMatchLength {
-				longestMatch = re
-				longestMatchLength = l
-				longestMatchStatusCode = c.UnauthorizedStatusCode
-			}
-		}
+// IsPreflightBypassed reports whether a cors preflight request from the origin
+// is sent upstream without checking authentik.
+func (c *Config) IsPreflightBypassed(origin string) bool {
+	if !c.CORSPreflight {
+		return false
 	}
 
-	// check if request path matches any of the redirect paths
-	for _, re := range c.RedirectPaths {
-		if re.MatchString(path) {
-			l := len(re.String())
-			if l > longestMatchLength {
-				longestMatch = re
-				longestMatchLength = l
-				longestMatchStatusCode = c.RedirectStatusCode
-			}
-		}
+	if len(c.CORSAllowedOrigins) == 0 {
+		return true
 	}
 
-	if longestMatch != nil {
-		// return the status code of the longest match
-		return longestMatchStatusCode
+	origin = strings.TrimSuffix(strings.ToLower(origin), "/")
+	for _, o := range c.CORSAllowedOrigins {
+		if o == origin {
+			return true
+		}
 	}
 
-	// allow request if no match is found
-	return http.StatusOK
+	return false
 }
@@ -0,0 +1,94 @@
+package authentik
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StrategyFailover   = "failover"
+	StrategyRoundRobin = "round-robin"
+)
+
+type endpoint struct {
+	address   string
+	downUntil time.Time
+}
+
+type endpointPool struct {
+	strategy string
+	cooldown time.Duration
+
+	mu        sync.Mutex
+	endpoints []*endpoint
+	next      int
+}
+
+func newEndpointPool(addresses []string, strategy string, cooldown time.Duration) *endpointPool {
+	endpoints := make([]*endpoint, 0, len(addresses))
+	for _, address := range addresses {
+		endpoints = append(endpoints, &endpoint{address: address})
+	}
+
+	return &endpointPool{
+		strategy:  strategy,
+		cooldown:  cooldown,
+		endpoints: endpoints,
+	}
+}
+
+// Pick returns the endpoint to send the next request to, skipping endpoints
+// that are marked down until their cool down elapses.
+func (p *endpointPool) Pick() *endpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	start := 0
+	if p.strategy == StrategyRoundRobin {
+		start = p.next
+		p.next = (p.next + 1) % len(p.endpoints)
+	}
+
+	now := time.Now()
+
+	var fallback *endpoint
+	for i := 0; i < len(p.endpoints); i++ {
+		e := p.endpoints[(start+i)%len(p.endpoints)]
+		if !now.Before(e.downUntil) {
+			return e
+		}
+
+		if fallback == nil || e.downUntil.Before(fallback.downUntil) {
+			fallback = e
+		}
+	}
+
+	// every endpoint is down, try the one that will recover first
+	return fallback
+}
+
+func (p *endpointPool) MarkDown(e *endpoint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e.downUntil = time.Now().Add(p.cooldown)
+}
+
+func (p *endpointPool) MarkUp(e *endpoint) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e.downUntil = time.Time{}
+}
+
+// Match returns the configured address that prefixes loc, if any.
+func (p *endpointPool) Match(loc string) (string, bool) {
+	for _, e := range p.endpoints {
+		if strings.HasPrefix(loc, e.address+BasePath) {
+			return e.address, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,205 @@
+package authentik_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/authentik"
+)
+
+func TestEndpoints(t *testing.T) {
+	t.Run("with failover strategy", func(t *testing.T) {
+		primaryCalls := 0
+		primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			primaryCalls++
+
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer primary.Close()
+
+		backupCalls := 0
+		backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			backupCalls++
+
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer backup.Close()
+
+		config := &authentik.Config{
+			Addresses:       []string{primary.URL, backup.URL},
+			AddressStrategy: authentik.StrategyFailover,
+			AddressCooldown: time.Minute,
+		}
+		client := authentik.NewClient(context.Background(), http.DefaultClient, config)
+
+		meta := &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{},
+		}
+
+		// check that the first request fails on the primary address
+		if _, err := client.Check(meta); err == nil {
+			t.Fatalf("expected error, got none")
+		}
+
+		// check that the next requests are sent to the backup address
+		for i := 0; i < 2; i++ {
+			resMeta, err := client.Check(meta)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !resMeta.Session.IsAuthenticated {
+				t.Errorf("expected request to be authenticated")
+			}
+		}
+
+		if primaryCalls != 1 {
+			t.Errorf("expected 1 primary call, got %d", primaryCalls)
+		}
+
+		if backupCalls != 2 {
+			t.Errorf("expected 2 backup calls, got %d", backupCalls)
+		}
+	})
+
+	t.Run("with failover strategy after cooldown", func(t *testing.T) {
+		primaryCalls := 0
+		primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			primaryCalls++
+
+			if primaryCalls == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer primary.Close()
+
+		backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer backup.Close()
+
+		config := &authentik.Config{
+			Addresses:       []string{primary.URL, backup.URL},
+			AddressStrategy: authentik.StrategyFailover,
+			AddressCooldown: 10 * time.Millisecond,
+		}
+		client := authentik.NewClient(context.Background(), http.DefaultClient, config)
+
+		meta := &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{},
+		}
+
+		_, _ = client.Check(meta)
+
+		// wait for the primary address cooldown to elapse
+		time.Sleep(30 * time.Millisecond)
+
+		if _, err := client.Check(meta); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// check that the primary address was retried
+		if primaryCalls != 2 {
+			t.Errorf("expected 2 primary calls, got %d", primaryCalls)
+		}
+	})
+
+	t.Run("with round robin strategy", func(t *testing.T) {
+		firstCalls := 0
+		first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			firstCalls++
+
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer first.Close()
+
+		secondCalls := 0
+		second := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			secondCalls++
+
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer second.Close()
+
+		config := &authentik.Config{
+			Addresses:       []string{first.URL, second.URL},
+			AddressStrategy: authentik.StrategyRoundRobin,
+			AddressCooldown: time.Minute,
+		}
+		client := authentik.NewClient(context.Background(), http.DefaultClient, config)
+
+		meta := &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{},
+		}
+
+		for i := 0; i < 4; i++ {
+			if _, err := client.Check(meta); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		// check that the requests were distributed between addresses
+		if firstCalls != 2 || secondCalls != 2 {
+			t.Errorf("expected 2 calls per address, got %d and %d", firstCalls, secondCalls)
+		}
+	})
+
+	t.Run("with mangled location from any address", func(t *testing.T) {
+		var backupURL string
+		backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Location", backupURL+"/outpost.goauthentik.io/start?rd=test")
+			w.WriteHeader(http.StatusFound)
+		}))
+		defer backup.Close()
+		backupURL = backup.URL
+
+		httpClient := &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				// don't follow redirects
+				return http.ErrUseLastResponse
+			},
+		}
+
+		down := httptest.NewServer(http.NotFoundHandler())
+		down.Close()
+
+		config := &authentik.Config{
+			Addresses:       []string{down.URL, backup.URL},
+			AddressStrategy: authentik.StrategyFailover,
+			AddressCooldown: time.Minute,
+		}
+		client := authentik.NewClient(context.Background(), httpClient, config)
+
+		meta := &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{},
+		}
+
+		// mark the first address as down
+		if _, err := client.Request(meta, "/outpost.goauthentik.io/start", ""); err == nil {
+			t.Fatalf("expected error, got none")
+		}
+
+		res, err := client.Request(meta, "/outpost.goauthentik.io/start", "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer func() { _ = res.Body.Close() }()
+
+		// check that the location points to the downstream host
+		expectedLocation := "https://example.com/outpost.goauthentik.io/start?rd=test"
+		if res.Header.Get("Location") != expectedLocation {
+			t.Errorf("expected location %s, got %s", expectedLocation, res.Header.Get("Location"))
+		}
+	})
+}
@@ -0,0 +1,77 @@
+package authentik
+
+import (
+	"context"
+	"sync"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/session"
+)
+
+type flightCall struct {
+	done    chan struct{}
+	session *session.Session
+	err     error
+}
+
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// Do executes fn once for every key while a call for the same key is in
+// flight, returning its result to all callers and whether it was shared. The
+// call runs in background, so a caller giving up when ctx is done doesn't
+// cancel it for the other callers.
+func (g *flightGroup) Do(ctx context.Context, key string, fn func() (*session.Session, error)) (*session.Session, bool, error) {
+	call, inFlight := g.start(key)
+	if !inFlight {
+		go g.run(key, call, fn)
+	}
+
+	// wait for the in flight call to finish
+	select {
+	case <-call.done:
+		return call.session, inFlight, call.err
+	case <-ctx.Done():
+		return nil, inFlight, ctx.Err()
+	}
+}
+
+// Go executes fn in background unless a call for the same key is already in
+// flight.
+func (g *flightGroup) Go(key string, fn func() (*session.Session, error)) {
+	call, inFlight := g.start(key)
+	if inFlight {
+		return
+	}
+
+	go g.run(key, call, fn)
+}
+
+func (g *flightGroup) start(key string) (*flightCall, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+
+	if call, ok := g.calls[key]; ok {
+		return call, true
+	}
+
+	call := &flightCall{done: make(chan struct{})}
+	g.calls[key] = call
+
+	return call, false
+}
+
+func (g *flightGroup) run(key string, call *flightCall, fn func() (*session.Session, error)) {
+	call.session, call.err = fn()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+
+	close(call.done)
+}
@@ -0,0 +1,72 @@
+package authentik
+
+import (
+	"regexp"
+	"strings"
+)
+
+// HostPattern matches request hosts, either exactly (example.com), by any
+// subdomain of a domain (*.example.com) or by a regex prefixed by ~.
+type HostPattern struct {
+	pattern string
+	exact   string
+	suffix  string
+	re      *regexp.Regexp
+}
+
+func ParseHostPattern(pattern string) (*HostPattern, error) {
+	p := &HostPattern{pattern: pattern}
+
+	switch {
+	case strings.HasPrefix(pattern, "~"):
+		re, err := regexp.Compile(pattern[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		p.re = re
+	case strings.HasPrefix(pattern, "*."):
+		p.suffix = normalizeHost(pattern[1:])
+	default:
+		p.exact = normalizeHost(pattern)
+	}
+
+	return p, nil
+}
+
+// Match reports whether the host, without port, matches the pattern.
+func (p *HostPattern) Match(host string) bool {
+	host = normalizeHost(host)
+
+	switch {
+	case p.re != nil:
+		return p.re.MatchString(host)
+	case p.suffix != "":
+		return len(host) > len(p.suffix) && strings.HasSuffix(host, p.suffix)
+	default:
+		return host == p.exact
+	}
+}
+
+func (p *HostPattern) String() string {
+	return p.pattern
+}
+
+func matchHosts(patterns []*HostPattern, host string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, p := range patterns {
+		if p.Match(host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func normalizeHost(host string) string {
+	// host names are case insensitive and may be fully qualified
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
@@ -0,0 +1,47 @@
+package authentik_test
+
+import (
+	"testing"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/authentik"
+)
+
+func TestHostPattern_Match(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		host     string
+		expected bool
+	}{
+		{"with exact host", "example.com", "example.com", true},
+		{"with exact host in other case", "Example.com", "EXAMPLE.com", true},
+		{"with exact host and trailing dot", "example.com", "example.com.", true},
+		{"with exact host and subdomain", "example.com", "api.example.com", false},
+		{"with wildcard and subdomain", "*.example.com", "api.example.com", true},
+		{"with wildcard and nested subdomain", "*.example.com", "v1.api.example.com", true},
+		{"with wildcard and apex domain", "*.example.com", "example.com", false},
+		{"with wildcard and other domain", "*.example.com", "badexample.com", false},
+		{"with matching regex", `~^(api|www)\.example\.com$`, "www.example.com", true},
+		{"with non matching regex", `~^(api|www)\.example\.com$`, "internal.example.com", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := authentik.ParseHostPattern(tt.pattern)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			matched := p.Match(tt.host)
+			if matched != tt.expected {
+				t.Errorf("expected match to be %t, got %t", tt.expected, matched)
+			}
+		})
+	}
+
+	t.Run("with invalid regex", func(t *testing.T) {
+		if _, err := authentik.ParseHostPattern("~("); err == nil {
+			t.Fatal("expected error for invalid regex, got none")
+		}
+	})
+}
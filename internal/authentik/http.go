@@ -2,7 +2,9 @@ package authentik
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/httputil"
 )
@@ -12,6 +14,11 @@ const (
 	CookiePrefix = "authentik_proxy_"
 
 	CachedHeaderKey = "X-Authentik-Traefik-Cached"
+	StaleHeaderKey  = "X-Authentik-Traefik-Stale"
+
+	BreakerHeaderKey = "X-Authentik-Traefik-Breaker"
+
+	LoginURLHeaderKey = "X-Authentik-Traefik-Login-Url"
 )
 
 func GetHeaders(res *http.Response) http.Header {
@@ -25,6 +32,44 @@ func GetHeaders(res *http.Response) http.Header {
 	return headers
 }
 
+func GetCacheLifetime(res *http.Response) (time.Duration, bool) {
+	// cache control directives take precedence over the expires header
+	if cc := res.Header.Values("Cache-Control"); len(cc) > 0 {
+		for _, directive := range strings.Split(strings.Join(cc, ","), ",") {
+			directive = strings.ToLower(strings.TrimSpace(directive))
+
+			switch {
+			case directive == "no-store" || directive == "no-cache":
+				return 0, true
+			case strings.HasPrefix(directive, "max-age="):
+				maxAge, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(directive, "max-age="), `"`))
+				if err != nil || maxAge < 0 {
+					return 0, true
+				}
+
+				return time.Duration(maxAge) * time.Second, true
+			}
+		}
+	}
+
+	if expires := res.Header.Get("Expires"); expires != "" {
+		expiresTime, err := http.ParseTime(expires)
+		if err != nil {
+			// invalid expires values represent a time in the past
+			return 0, true
+		}
+
+		now := time.Now()
+		if date, err := http.ParseTime(res.Header.Get("Date")); err == nil {
+			now = date
+		}
+
+		return max(expiresTime.Sub(now), 0), true
+	}
+
+	return 0, false
+}
+
 func GetCookies(cookier httputil.Cookier) []*http.Cookie {
 	cookies := make([]*http.Cookie, 0, 1)
 
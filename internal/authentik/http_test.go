@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/authentik"
 	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/httputil"
@@ -302,3 +303,69 @@ func TestGetResponseMangler(t *testing.T) {
 		}
 	})
 }
+
+func TestGetCacheLifetime(t *testing.T) {
+	tests := []struct {
+		name             string
+		header           http.Header
+		expectedLifetime time.Duration
+		expectedOk       bool
+	}{
+		{
+			name:             "without cache headers",
+			header:           http.Header{},
+			expectedLifetime: 0,
+			expectedOk:       false,
+		},
+		{
+			name:             "with max age",
+			header:           http.Header{"Cache-Control": []string{"private, max-age=30"}},
+			expectedLifetime: 30 * time.Second,
+			expectedOk:       true,
+		},
+		{
+			name:             "with no store",
+			header:           http.Header{"Cache-Control": []string{"no-store"}},
+			expectedLifetime: 0,
+			expectedOk:       true,
+		},
+		{
+			name:             "with no cache before max age",
+			header:           http.Header{"Cache-Control": []string{"no-cache, max-age=30"}},
+			expectedLifetime: 0,
+			expectedOk:       true,
+		},
+		{
+			name: "with expires",
+			header: http.Header{
+				"Date":    []string{"Mon, 02 Jan 2006 15:04:05 GMT"},
+				"Expires": []string{"Mon, 02 Jan 2006 15:05:05 GMT"},
+			},
+			expectedLifetime: time.Minute,
+			expectedOk:       true,
+		},
+		{
+			name:             "with invalid expires",
+			header:           http.Header{"Expires": []string{"0"}},
+			expectedLifetime: 0,
+			expectedOk:       true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res := &http.Response{Header: test.header}
+
+			lifetime, ok := authentik.GetCacheLifetime(res)
+
+			// check that the lifetime is the expected one
+			if lifetime != test.expectedLifetime {
+				t.Errorf("expected lifetime %v, got %v", test.expectedLifetime, lifetime)
+			}
+
+			if ok != test.expectedOk {
+				t.Errorf("expected ok to be %t, got %t", test.expectedOk, ok)
+			}
+		})
+	}
+}
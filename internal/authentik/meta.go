@@ -1,6 +1,7 @@
 package authentik
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 
@@ -8,12 +9,19 @@ import (
 )
 
 type RequestMeta struct {
+	Context context.Context //nolint:containedctx
 	URL     *url.URL
 	Cookies []*http.Cookie
+
+	// downstream w3c trace context headers
+	Traceparent string
+	Tracestate  string
 }
 
 type ResponseMeta struct {
-	URL     *url.URL
-	Cached  bool
-	Session *session.Session
+	URL       *url.URL
+	Cached    bool
+	Coalesced bool
+	Stale     bool
+	Session   *session.Session
 }
@@ -0,0 +1,84 @@
+package authentik
+
+import (
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/metrics"
+)
+
+const (
+	OutcomeAuthenticated   = "authenticated"
+	OutcomeUnauthenticated = "unauthenticated"
+	OutcomeStale           = "stale"
+	OutcomeError           = "error"
+
+	CacheHit    = "hit"
+	CacheMiss   = "miss"
+	CacheBypass = "bypass"
+	CacheStale  = "stale"
+)
+
+//nolint:gochecknoglobals
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type clientMetrics struct {
+	checks  *metrics.CounterVec
+	cache   *metrics.CounterVec
+	latency *metrics.Histogram
+}
+
+func newClientMetrics() *clientMetrics {
+	return &clientMetrics{
+		checks: metrics.NewCounterVec(
+			"traefik_authentik_checks_total",
+			"Authentication checks by outcome.",
+			"outcome",
+		),
+		cache: metrics.NewCounterVec(
+			"traefik_authentik_cache_requests_total",
+			"Session cache lookups by result.",
+			"result",
+		),
+		latency: metrics.NewHistogram(
+			"traefik_authentik_request_duration_seconds",
+			"Duration of requests sent to authentik.",
+			latencyBuckets,
+		),
+	}
+}
+
+// Collectors returns the metrics of the client, including the state of the
+// circuit breaker and of the session cache.
+func (c *Client) Collectors() []metrics.Collector {
+	return []metrics.Collector{
+		c.metrics.checks,
+		c.metrics.cache,
+		c.metrics.latency,
+		metrics.NewGaugeFunc(
+			"traefik_authentik_circuit_breaker_state",
+			"State of the circuit breaker (0 closed, 1 open, 2 half-open).",
+			func() float64 { return float64(c.breaker.State()) },
+		),
+		metrics.NewGaugeFunc(
+			"traefik_authentik_cache_entries",
+			"Number of cached sessions.",
+			func() float64 { return float64(c.session.Stats().Entries) },
+		),
+		metrics.NewGaugeFunc(
+			"traefik_authentik_cache_bytes",
+			"Approximate size in bytes of the cached sessions.",
+			func() float64 { return float64(c.session.Stats().Bytes) },
+		),
+		metrics.NewCounterFunc(
+			"traefik_authentik_cache_evictions_total",
+			"Cached sessions evicted to keep the cache within its limits.",
+			func() float64 { return float64(c.session.Stats().Evictions) },
+		),
+	}
+}
+
+func getOutcome(authenticated bool) string {
+	if authenticated {
+		return OutcomeAuthenticated
+	}
+
+	return OutcomeUnauthenticated
+}
@@ -0,0 +1,123 @@
+package authentik
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/session"
+)
+
+// sharedCache is a session cache and in flight check map shared by every
+// middleware instance with identical authentik, http client and cache settings.
+type sharedCache struct {
+	session session.Client
+	flight  *flightGroup
+	cancel  context.CancelFunc
+	refs    int
+}
+
+type cacheRegistry struct {
+	mu      sync.Mutex
+	entries map[string]*sharedCache
+}
+
+//nolint:gochecknoglobals
+var registry = &cacheRegistry{entries: make(map[string]*sharedCache)}
+
+// Acquire returns the shared cache for config, creating it if needed. The
+// reference is released once ctx is done, and the cache is cleaned up when
+// no middleware instance references it anymore.
+func (r *cacheRegistry) Acquire(ctx context.Context, config *Config, sessionConfig *session.Config) *sharedCache {
+	key := getRegistryKey(config, sessionConfig)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.entries[key]
+	if !ok {
+		// the shared cache outlives the instance that created it
+		cacheCtx, cancel := context.WithCancel(context.Background())
+
+		entry = &sharedCache{
+			session: session.NewClient(cacheCtx, sessionConfig),
+			flight:  &flightGroup{},
+			cancel:  cancel,
+		}
+
+		r.entries[key] = entry
+	}
+
+	entry.refs++
+
+	// traefik cancels the context of the previous instances after a
+	// configuration reload, once the new ones already hold a reference
+	go func() {
+		<-ctx.Done()
+		r.release(key, entry)
+	}()
+
+	return entry
+}
+
+func (r *cacheRegistry) release(key string, entry *sharedCache) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry.refs--
+	if entry.refs > 0 {
+		return
+	}
+
+	if r.entries[key] == entry {
+		delete(r.entries, key)
+	}
+
+	entry.cancel()
+}
+
+// ClaimSnapshotFile reserves the cache snapshot file of config for the
+// middleware instance name, or for every instance sharing the same cache.
+func ClaimSnapshotFile(ctx context.Context, config *Config, name string) error {
+	owner := "instance\n" + name
+	if config.CacheShared {
+		owner = "shared\n" + getRegistryKey(config, newSessionConfig(config))
+	}
+
+	return session.ClaimSnapshotFile(ctx, config.CacheSnapshotFile, owner)
+}
+
+func getRegistryKey(config *Config, sessionConfig *session.Config) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s\n", strings.Join(config.Addresses, ","))
+	fmt.Fprintf(&b, "%s\n%s\n", config.AddressStrategy, config.AddressCooldown)
+	fmt.Fprintf(&b, "%d\n%s\n%s\n", config.RetryAttempts, config.RetryBackoff, config.RetryMaxBackoff)
+	fmt.Fprintf(&b, "%s\n%s\n%s\n", config.CacheDuration, config.CacheNegativeDuration, config.CacheRevalidateWindow)
+	fmt.Fprintf(&b, "%t\n", config.CacheRespectHeaders)
+	fmt.Fprintf(&b, "%s\n", config.CacheSignOutScope)
+	fmt.Fprintf(&b, "%s\n%s\n", sessionConfig.Duration, sessionConfig.StaleDuration)
+	fmt.Fprintf(&b, "%d\n%d\n", sessionConfig.MaxEntries, sessionConfig.MaxBytes)
+	fmt.Fprintf(&b, "%s\n%s\n", sessionConfig.SnapshotFile, sessionConfig.SnapshotInterval)
+	fmt.Fprintf(&b, "%s\n%s\n%s\n", sessionConfig.Backend, sessionConfig.Prefix, sessionConfig.EncryptionKey)
+
+	// shared checks are sent with the http client of the first instance
+	if config.HTTPClient != nil {
+		tls := config.HTTPClient.TLS
+		fmt.Fprintf(&b, "%s\n", config.HTTPClient.Timeout)
+		fmt.Fprintf(&b, "%s\n%s\n%s\n", tls.CA, tls.Cert, tls.Key)
+		fmt.Fprintf(&b, "%d\n%d\n%t\n", tls.MinVersion, tls.MaxVersion, tls.InsecureSkipVerify)
+	}
+
+	if sessionConfig.Redis != nil {
+		fmt.Fprintf(&b, "%s\n%d\n%s\n", sessionConfig.Redis.Address, sessionConfig.Redis.Database, sessionConfig.Redis.Password)
+	}
+
+	// never keep secrets in memory as plain map keys
+	hash := sha256.Sum256([]byte(b.String()))
+
+	return hex.EncodeToString(hash[:])
+}
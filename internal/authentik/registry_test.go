@@ -0,0 +1,274 @@
+package authentik_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/authentik"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/httpclient"
+)
+
+func TestCheck_SharedCache(t *testing.T) {
+	t.Run("with identical settings", func(t *testing.T) {
+		var akCalls atomic.Int32
+		server := newCountingServer(t, &akCalls, http.StatusOK)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		newConfig := func() *authentik.Config {
+			return &authentik.Config{
+				Addresses:             []string{server.URL},
+				CacheDuration:         time.Minute,
+				CacheNegativeDuration: time.Minute,
+				CacheShared:           true,
+			}
+		}
+
+		first := authentik.NewClient(ctx, server.Client(), newConfig())
+		second := authentik.NewClient(ctx, server.Client(), newConfig())
+
+		if _, err := first.Check(newSessionMeta()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		resMeta, err := second.Check(newSessionMeta())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// check that the session cached by the first instance is used
+		if !resMeta.Cached {
+			t.Error("expected response to be cached")
+		}
+
+		if akCalls.Load() != 1 {
+			t.Errorf("expected 1 authentik call, got %d", akCalls.Load())
+		}
+	})
+
+	t.Run("with different settings", func(t *testing.T) {
+		var akCalls atomic.Int32
+		server := newCountingServer(t, &akCalls, http.StatusOK)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		first := authentik.NewClient(ctx, server.Client(), &authentik.Config{
+			Addresses:     []string{server.URL},
+			CacheDuration: time.Minute,
+			CacheShared:   true,
+		})
+		second := authentik.NewClient(ctx, server.Client(), &authentik.Config{
+			Addresses:     []string{server.URL},
+			CacheDuration: 2 * time.Minute,
+			CacheShared:   true,
+		})
+
+		_, _ = first.Check(newSessionMeta())
+
+		resMeta, err := second.Check(newSessionMeta())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if resMeta.Cached {
+			t.Error("expected response not to be cached")
+		}
+	})
+
+	t.Run("with concurrent checks", func(t *testing.T) {
+		var akCalls atomic.Int32
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			akCalls.Add(1)
+			<-release
+
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		newConfig := func() *authentik.Config {
+			return &authentik.Config{
+				Addresses:     []string{server.URL},
+				CacheDuration: time.Minute,
+				CacheShared:   true,
+			}
+		}
+
+		clients := []*authentik.Client{
+			authentik.NewClient(ctx, server.Client(), newConfig()),
+			authentik.NewClient(ctx, server.Client(), newConfig()),
+		}
+
+		var wg sync.WaitGroup
+		for _, client := range clients {
+			wg.Add(1)
+			go func(client *authentik.Client) {
+				defer wg.Done()
+				_, _ = client.Check(newSessionMeta())
+			}(client)
+		}
+
+		// give both instances time to join the in flight check
+		time.Sleep(50 * time.Millisecond)
+
+		close(release)
+		wg.Wait()
+
+		// check that the instances shared a single check
+		if akCalls.Load() != 1 {
+			t.Errorf("expected 1 authentik call, got %d", akCalls.Load())
+		}
+	})
+
+	t.Run("with different http client settings", func(t *testing.T) {
+		var akCalls atomic.Int32
+		server := newCountingServer(t, &akCalls, http.StatusOK)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		newConfig := func(timeout time.Duration) *authentik.Config {
+			return &authentik.Config{
+				Addresses:     []string{server.URL},
+				CacheDuration: time.Minute,
+				CacheShared:   true,
+				HTTPClient:    &httpclient.Config{Timeout: timeout},
+			}
+		}
+
+		first := authentik.NewClient(ctx, server.Client(), newConfig(time.Second))
+		second := authentik.NewClient(ctx, server.Client(), newConfig(2*time.Second))
+
+		_, _ = first.Check(newSessionMeta())
+
+		resMeta, err := second.Check(newSessionMeta())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if resMeta.Cached {
+			t.Error("expected response not to be cached")
+		}
+	})
+
+	t.Run("when not shared", func(t *testing.T) {
+		var akCalls atomic.Int32
+		server := newCountingServer(t, &akCalls, http.StatusOK)
+
+		config := &authentik.Config{
+			Addresses:     []string{server.URL},
+			CacheDuration: time.Minute,
+		}
+
+		first := authentik.NewClient(context.Background(), server.Client(), config)
+		second := authentik.NewClient(context.Background(), server.Client(), config)
+
+		_, _ = first.Check(newSessionMeta())
+
+		resMeta, err := second.Check(newSessionMeta())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if resMeta.Cached {
+			t.Error("expected response not to be cached")
+		}
+	})
+
+	t.Run("after every instance is discarded", func(t *testing.T) {
+		var akCalls atomic.Int32
+		server := newCountingServer(t, &akCalls, http.StatusOK)
+
+		newConfig := func() *authentik.Config {
+			return &authentik.Config{
+				Addresses:     []string{server.URL},
+				CacheDuration: time.Minute,
+				CacheShared:   true,
+			}
+		}
+
+		firstCtx, firstCancel := context.WithCancel(context.Background())
+		first := authentik.NewClient(firstCtx, server.Client(), newConfig())
+		_, _ = first.Check(newSessionMeta())
+
+		// a reloaded instance keeps the cache alive
+		secondCtx, secondCancel := context.WithCancel(context.Background())
+		defer secondCancel()
+
+		second := authentik.NewClient(secondCtx, server.Client(), newConfig())
+		firstCancel()
+
+		time.Sleep(10 * time.Millisecond)
+
+		if resMeta, _ := second.Check(newSessionMeta()); !resMeta.Cached {
+			t.Error("expected response to be cached after reload")
+		}
+
+		secondCancel()
+
+		time.Sleep(10 * time.Millisecond)
+
+		third := authentik.NewClient(context.Background(), server.Client(), newConfig())
+		if resMeta, _ := third.Check(newSessionMeta()); resMeta.Cached {
+			t.Error("expected cache to be cleaned up")
+		}
+	})
+}
+
+func TestClaimSnapshotFile(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newConfig := func(shared bool) *authentik.Config {
+		return &authentik.Config{
+			Addresses:          []string{"https://authentik.example.com"},
+			CacheDuration:      time.Minute,
+			CacheShared:        shared,
+			CacheSnapshotFile:  filepath.Join(t.TempDir(), "sessions.snapshot"),
+			CacheEncryptionKey: "secret",
+		}
+	}
+
+	t.Run("with instances of different middlewares", func(t *testing.T) {
+		config := newConfig(false)
+
+		if err := authentik.ClaimSnapshotFile(ctx, config, "first"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if err := authentik.ClaimSnapshotFile(ctx, config, "second"); err == nil {
+			t.Error("expected error, got none")
+		}
+	})
+
+	t.Run("with instances sharing the cache", func(t *testing.T) {
+		config := newConfig(true)
+
+		if err := authentik.ClaimSnapshotFile(ctx, config, "first"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if err := authentik.ClaimSnapshotFile(ctx, config, "second"); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+
+		// a different shared cache can't write the same snapshot
+		other := *config
+		other.CacheDuration = 2 * time.Minute
+
+		if err := authentik.ClaimSnapshotFile(ctx, &other, "third"); err == nil {
+			t.Error("expected error, got none")
+		}
+	})
+}
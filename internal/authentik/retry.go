@@ -0,0 +1,82 @@
+package authentik
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+func (c *Client) requestWithRetry(meta *RequestMeta, path string, query string) (*http.Response, error) {
+	ctx := getContext(meta)
+
+	for attempt := 0; ; attempt++ {
+		res, err := c.request(meta, path, query)
+		if attempt >= c.config.RetryAttempts || !isRetryable(ctx, res, err) {
+			return res, err
+		}
+
+		// stop retrying if the backoff would exceed the request deadline
+		wait := c.getBackoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return res, err
+		}
+
+		if res != nil {
+			// discard failed response before retrying
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (c *Client) getBackoff(attempt int) time.Duration {
+	backoff := c.config.RetryBackoff << attempt
+	if c.config.RetryMaxBackoff > 0 && (backoff <= 0 || backoff > c.config.RetryMaxBackoff) {
+		backoff = c.config.RetryMaxBackoff
+	}
+
+	if backoff <= 1 {
+		return backoff
+	}
+
+	// wait at least half of the backoff, with random jitter for the rest
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(backoff-half))) //nolint:gosec
+}
+
+func isRetryable(ctx context.Context, res *http.Response, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrCircuitOpen) {
+		return false
+	}
+
+	if err != nil {
+		// retry transport errors such as dropped keep alive connections
+		return true
+	}
+
+	switch res.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+func getContext(meta *RequestMeta) context.Context {
+	if meta.Context == nil {
+		return context.Background()
+	}
+
+	return meta.Context
+}
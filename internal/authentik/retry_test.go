@@ -0,0 +1,192 @@
+package authentik_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/authentik"
+)
+
+func TestRetry(t *testing.T) {
+	t.Run("with transient check failures", func(t *testing.T) {
+		var akCalls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if akCalls.Add(1) < 3 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		config := &authentik.Config{
+			Addresses:       []string{server.URL},
+			RetryAttempts:   2,
+			RetryBackoff:    time.Millisecond,
+			RetryMaxBackoff: 10 * time.Millisecond,
+		}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		meta := &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{},
+		}
+
+		resMeta, err := client.Check(meta)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// check that the request was authenticated after retrying
+		if !resMeta.Session.IsAuthenticated {
+			t.Errorf("expected request to be authenticated")
+		}
+
+		expectedCalls := int32(3)
+		if akCalls.Load() != expectedCalls {
+			t.Errorf("expected %d authentik calls, got %d", expectedCalls, akCalls.Load())
+		}
+	})
+
+	t.Run("with exhausted check retries", func(t *testing.T) {
+		var akCalls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			akCalls.Add(1)
+
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		config := &authentik.Config{
+			Addresses:       []string{server.URL},
+			RetryAttempts:   2,
+			RetryBackoff:    time.Millisecond,
+			RetryMaxBackoff: 10 * time.Millisecond,
+		}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		meta := &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{},
+		}
+
+		if _, err := client.Check(meta); err == nil {
+			t.Fatalf("expected error, got none")
+		}
+
+		expectedCalls := int32(3)
+		if akCalls.Load() != expectedCalls {
+			t.Errorf("expected %d authentik calls, got %d", expectedCalls, akCalls.Load())
+		}
+	})
+
+	t.Run("with non retryable check response", func(t *testing.T) {
+		var akCalls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			akCalls.Add(1)
+
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		config := &authentik.Config{
+			Addresses:       []string{server.URL},
+			RetryAttempts:   2,
+			RetryBackoff:    time.Millisecond,
+			RetryMaxBackoff: 10 * time.Millisecond,
+		}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		meta := &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{},
+		}
+
+		if _, err := client.Check(meta); err == nil {
+			t.Fatalf("expected error, got none")
+		}
+
+		expectedCalls := int32(1)
+		if akCalls.Load() != expectedCalls {
+			t.Errorf("expected %d authentik calls, got %d", expectedCalls, akCalls.Load())
+		}
+	})
+
+	t.Run("with backoff exceeding request deadline", func(t *testing.T) {
+		var akCalls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			akCalls.Add(1)
+
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer server.Close()
+
+		config := &authentik.Config{
+			Addresses:       []string{server.URL},
+			RetryAttempts:   2,
+			RetryBackoff:    time.Minute,
+			RetryMaxBackoff: time.Minute,
+		}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		meta := &authentik.RequestMeta{
+			Context: ctx,
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{},
+		}
+
+		if _, err := client.Check(meta); err == nil {
+			t.Fatalf("expected error, got none")
+		}
+
+		// check that the check was not retried past the deadline
+		expectedCalls := int32(1)
+		if akCalls.Load() != expectedCalls {
+			t.Errorf("expected %d authentik calls, got %d", expectedCalls, akCalls.Load())
+		}
+	})
+
+	t.Run("with failed flow request", func(t *testing.T) {
+		var akCalls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			akCalls.Add(1)
+
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer server.Close()
+
+		config := &authentik.Config{
+			Addresses:       []string{server.URL},
+			RetryAttempts:   2,
+			RetryBackoff:    time.Millisecond,
+			RetryMaxBackoff: 10 * time.Millisecond,
+		}
+		client := authentik.NewClient(context.Background(), server.Client(), config)
+
+		meta := &authentik.RequestMeta{
+			URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/protected"},
+			Cookies: []*http.Cookie{},
+		}
+
+		res, err := client.Request(meta, "/outpost.goauthentik.io/callback", "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer func() { _ = res.Body.Close() }()
+
+		// check that flow requests are never retried
+		expectedCalls := int32(1)
+		if akCalls.Load() != expectedCalls {
+			t.Errorf("expected %d authentik calls, got %d", expectedCalls, akCalls.Load())
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package authentik
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	// ActionSkip sends the request upstream without checking authentik.
+	ActionSkip = "skip"
+	// ActionAllow sends the request upstream, anonymously if not authenticated.
+	ActionAllow = "allow"
+	// ActionDeny returns the rule status code if not authenticated.
+	ActionDeny = "deny"
+	// ActionRedirect redirects to authentik if not authenticated.
+	ActionRedirect = "redirect"
+	// ActionNegotiate redirects browser navigations to authentik and returns
+	// the unauthorized status code to other clients if not authenticated.
+	ActionNegotiate = "negotiate"
+	// ActionInherit takes the action and status code of the next matching rule,
+	// or returns the rule status code if not authenticated when none matches.
+	ActionInherit = "inherit"
+)
+
+//nolint:gochecknoglobals
+var defaultRule = &Rule{Action: ActionAllow, StatusCode: http.StatusOK}
+
+// Rule decides how a request is handled. Every matcher that is set must match
+// the request for the rule to apply.
+type Rule struct {
+	Name string
+
+	Path    *regexp.Regexp
+	Hosts   []*HostPattern
+	Methods []string
+	Headers map[string]*regexp.Regexp
+	Query   map[string]*regexp.Regexp
+
+	Action     string
+	StatusCode int
+
+	// authenticated users must be members of any or all of the groups
+	Groups   []string
+	MatchAll bool
+}
+
+// Match reports whether the rule applies to the request, using the resolved
+// request url for the host, path and query.
+func (r *Rule) Match(u *url.URL, req *http.Request) bool {
+	if r.Path != nil && !r.Path.MatchString(u.Path) {
+		return false
+	}
+
+	if !matchHosts(r.Hosts, u.Hostname()) {
+		return false
+	}
+
+	if len(r.Methods) > 0 && !matchMethod(r.Methods, req.Method) {
+		return false
+	}
+
+	for k, re := range r.Headers {
+		if !matchValues(re, req.Header.Values(k)) {
+			return false
+		}
+	}
+
+	if len(r.Query) > 0 {
+		query := u.Query()
+		for k, re := range r.Query {
+			if !matchValues(re, query[k]) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// IsAuthorized reports whether a user with the given groups satisfies the
+// groups required by the rule, if any.
+func (r *Rule) IsAuthorized(groups []string) bool {
+	if len(r.Groups) == 0 {
+		return true
+	}
+
+	member := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		member[g] = true
+	}
+
+	for _, g := range r.Groups {
+		if member[g] && !r.MatchAll {
+			return true
+		}
+
+		if !member[g] && r.MatchAll {
+			return false
+		}
+	}
+
+	return r.MatchAll
+}
+
+// GetRule returns the first rule matching the request, or a rule allowing the
+// request if none does.
+func (c *Config) GetRule(u *url.URL, req *http.Request) *Rule {
+	for idx, rule := range c.Rules {
+		if !rule.Match(u, req) {
+			continue
+		}
+
+		if rule.Action == ActionInherit {
+			return c.inheritRule(rule, idx+1, u, req)
+		}
+
+		return rule
+	}
+
+	return defaultRule
+}
+
+// inheritRule returns a copy of the rule with the action and status code of
+// the first rule after it matching the request, or denying the request if none
+// does.
+func (c *Config) inheritRule(rule *Rule, start int, u *url.URL, req *http.Request) *Rule {
+	inherited := *rule
+	inherited.Action = ActionDeny
+
+	for _, next := range c.Rules[start:] {
+		if next.Action != ActionInherit && next.Match(u, req) {
+			inherited.Action = next.Action
+			inherited.StatusCode = next.StatusCode
+
+			break
+		}
+	}
+
+	return &inherited
+}
+
+func matchMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchValues(re *regexp.Regexp, values []string) bool {
+	// the parameter must be present with at least one matching value
+	for _, v := range values {
+		if re.MatchString(v) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,216 @@
+package authentik_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/authentik"
+)
+
+func TestRule_Match(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     authentik.Rule
+		target   string
+		method   string
+		header   http.Header
+		expected bool
+	}{
+		{
+			name:     "with no matchers",
+			rule:     authentik.Rule{},
+			target:   "http://example.com/",
+			expected: true,
+		},
+		{
+			name:     "with matching path",
+			rule:     authentik.Rule{Path: regexp.MustCompile("^/api")},
+			target:   "http://example.com/api/users",
+			expected: true,
+		},
+		{
+			name:     "with non matching path",
+			rule:     authentik.Rule{Path: regexp.MustCompile("^/api")},
+			target:   "http://example.com/admin",
+			expected: false,
+		},
+		{
+			name:     "with matching host and port",
+			rule:     authentik.Rule{Hosts: mustParseHostPatterns(t, "api.example.com")},
+			target:   "http://api.example.com:8080/",
+			expected: true,
+		},
+		{
+			name:     "with non matching host",
+			rule:     authentik.Rule{Hosts: mustParseHostPatterns(t, "api.example.com")},
+			target:   "http://example.com/",
+			expected: false,
+		},
+		{
+			name:     "with matching method",
+			rule:     authentik.Rule{Methods: []string{"get", "HEAD"}},
+			target:   "http://example.com/",
+			method:   http.MethodGet,
+			expected: true,
+		},
+		{
+			name:     "with non matching method",
+			rule:     authentik.Rule{Methods: []string{"GET", "HEAD"}},
+			target:   "http://example.com/",
+			method:   http.MethodPost,
+			expected: false,
+		},
+		{
+			name:     "with matching header",
+			rule:     authentik.Rule{Headers: map[string]*regexp.Regexp{"x-api-version": regexp.MustCompile("^2$")}},
+			target:   "http://example.com/",
+			header:   http.Header{"X-Api-Version": {"1", "2"}},
+			expected: true,
+		},
+		{
+			name:     "with missing header",
+			rule:     authentik.Rule{Headers: map[string]*regexp.Regexp{"X-Api-Version": regexp.MustCompile("")}},
+			target:   "http://example.com/",
+			expected: false,
+		},
+		{
+			name:     "with matching query",
+			rule:     authentik.Rule{Query: map[string]*regexp.Regexp{"format": regexp.MustCompile("^json$")}},
+			target:   "http://example.com/?format=json",
+			expected: true,
+		},
+		{
+			name:     "with non matching query",
+			rule:     authentik.Rule{Query: map[string]*regexp.Regexp{"format": regexp.MustCompile("^json$")}},
+			target:   "http://example.com/?format=xml",
+			expected: false,
+		},
+		{
+			name: "with partially matching matchers",
+			rule: authentik.Rule{
+				Path:    regexp.MustCompile("^/api"),
+				Methods: []string{"POST"},
+			},
+			target:   "http://example.com/api",
+			method:   http.MethodGet,
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			req := httptest.NewRequest(method, tt.target, nil)
+			for k, vs := range tt.header {
+				for _, v := range vs {
+					req.Header.Add(k, v)
+				}
+			}
+
+			u, err := url.Parse(tt.target)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			matched := tt.rule.Match(u, req)
+			if matched != tt.expected {
+				t.Errorf("expected match to be %t, got %t", tt.expected, matched)
+			}
+		})
+	}
+}
+
+func mustParseHostPatterns(t *testing.T, patterns ...string) []*authentik.HostPattern {
+	t.Helper()
+
+	hosts := make([]*authentik.HostPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		host, err := authentik.ParseHostPattern(pattern)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	return hosts
+}
+
+func TestRule_IsAuthorized(t *testing.T) {
+	tests := []struct {
+		name     string
+		groups   []string
+		matchAll bool
+		user     []string
+		expected bool
+	}{
+		{"with no required groups", nil, false, nil, true},
+		{"with any match and one group", []string{"admins", "operators"}, false, []string{"users", "operators"}, true},
+		{"with any match and no group", []string{"admins", "operators"}, false, []string{"users"}, false},
+		{"with all match and every group", []string{"admins", "operators"}, true, []string{"operators", "users", "admins"}, true},
+		{"with all match and one group", []string{"admins", "operators"}, true, []string{"admins"}, false},
+		{"with no user groups", []string{"admins", "operators"}, false, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := &authentik.Rule{
+				Groups:   tt.groups,
+				MatchAll: tt.matchAll,
+			}
+
+			isAuthorized := rule.IsAuthorized(tt.user)
+			if isAuthorized != tt.expected {
+				t.Errorf("expected isAuthorized to be %t, got %t", tt.expected, isAuthorized)
+			}
+		})
+	}
+}
+
+func TestGetRule(t *testing.T) {
+	cfg := authentik.Config{
+		Rules: []*authentik.Rule{
+			{Name: "health", Path: regexp.MustCompile("^/health$"), Action: authentik.ActionSkip, StatusCode: http.StatusOK},
+			{Name: "admin", Path: regexp.MustCompile("^/admin"), Action: authentik.ActionInherit, StatusCode: http.StatusUnauthorized},
+			{Name: "api", Path: regexp.MustCompile("^/api"), Action: authentik.ActionDeny, StatusCode: http.StatusUnauthorized},
+			{Name: "api-docs", Path: regexp.MustCompile("^/api/docs"), Action: authentik.ActionAllow, StatusCode: http.StatusOK},
+			{Name: "admin-login", Path: regexp.MustCompile("^/admin/login"), Action: authentik.ActionRedirect, StatusCode: http.StatusFound},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedName   string
+		expectedAction string
+	}{
+		{"with first matching rule", "/health", "health", authentik.ActionSkip},
+		{"with first of several matching rules", "/api/docs", "api", authentik.ActionDeny},
+		{"with no matching rule", "/users", "", authentik.ActionAllow},
+		{"with inheriting rule", "/admin/login", "admin", authentik.ActionRedirect},
+		{"with inheriting rule and no next rule", "/admin", "admin", authentik.ActionDeny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com"+tt.path, nil)
+
+			rule := cfg.GetRule(req.URL, req)
+
+			if rule.Name != tt.expectedName {
+				t.Errorf("expected rule %q, got %q", tt.expectedName, rule.Name)
+			}
+
+			if rule.Action != tt.expectedAction {
+				t.Errorf("expected action %s, got %s", tt.expectedAction, rule.Action)
+			}
+		})
+	}
+}
@@ -10,6 +10,8 @@ const (
 	StartPath = BasePath + "/start"
 	AuthPath  = BasePath + "/auth"
 	NginxPath = AuthPath + "/nginx"
+
+	SignOutPath = BasePath + "/sign_out"
 )
 
 func IsAuthentikPathAllowed(akPath string) bool {
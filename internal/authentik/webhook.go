@@ -0,0 +1,121 @@
+package authentik
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/session"
+)
+
+var ErrInvalidNotification = errors.New("invalid authentik notification")
+
+// notificationUser is the user serialized in authentik events.
+type notificationUser struct {
+	UID      string `json:"uid"`
+	Username string `json:"username"`
+}
+
+// notificationModel is the model affected by an authentik event.
+type notificationModel struct {
+	ModelName string `json:"model_name"` //nolint:tagliatelle
+	Name      string `json:"name"`
+	UID       string `json:"uid"`
+
+	// owner of the model, set by custom webhook mappings on sessions
+	User *notificationUser `json:"user"`
+}
+
+// notificationEvent is the event serialized by custom webhook mappings.
+type notificationEvent struct {
+	Action  string            `json:"action"`
+	User    *notificationUser `json:"user"`
+	Context struct {
+		Model *notificationModel `json:"model"`
+	} `json:"context"`
+}
+
+// notification is the payload sent by the authentik webhook transport, either
+// the default one or one of a custom mapping including the serialized event,
+// as the payload itself or under the event key.
+type notification struct {
+	// user of the event in the default payload
+	EventUserUsername string `json:"event_user_username"` //nolint:tagliatelle
+
+	notificationEvent
+
+	Event *notificationEvent `json:"event"`
+}
+
+// ParseNotification returns the identifiers of the users whose sessions are
+// affected by an authentik notification. Logout and user write events affect
+// the user of the event, and model events the model they act upon.
+func ParseNotification(body []byte) ([]string, error) {
+	var n notification
+	if err := json.Unmarshal(body, &n); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidNotification, err)
+	}
+
+	users := n.notificationEvent.getUsers()
+
+	if n.Event != nil {
+		users = append(users, n.Event.getUsers()...)
+	} else if n.Action == "" {
+		// the default payload only includes the user of the event
+		users = append(users, session.UserByUsername(n.EventUserUsername))
+	}
+
+	// drop empty and duplicated identifiers
+	seen := make(map[string]struct{}, len(users))
+	result := make([]string, 0, len(users))
+	for _, user := range users {
+		if _, ok := seen[user]; ok || user == "" {
+			continue
+		}
+
+		seen[user] = struct{}{}
+		result = append(result, user)
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("%w: no user found", ErrInvalidNotification)
+	}
+
+	return result, nil
+}
+
+func (e *notificationEvent) getUsers() []string {
+	switch {
+	case e.Action == "logout" || e.Action == "user_write":
+		// the user of the event is the one signing out or being written
+		if e.User != nil {
+			return []string{session.UserByUID(e.User.UID), session.UserByUsername(e.User.Username)}
+		}
+	case e.Action == "" || strings.HasPrefix(e.Action, "model_"):
+		// the user of the event only triggered the change of the model, and
+		// mappings serializing only the context carry no action
+		return e.Context.Model.getUsers()
+	}
+
+	return nil
+}
+
+func (m *notificationModel) getUsers() []string {
+	if m == nil {
+		return nil
+	}
+
+	switch m.ModelName {
+	case "user":
+		// user models are named after the username
+		return []string{session.UserByUID(m.UID), session.UserByUsername(m.Name)}
+	case "authenticatedsession":
+		// revoked sessions belong to their owner
+		if m.User != nil {
+			return []string{session.UserByUID(m.User.UID), session.UserByUsername(m.User.Username)}
+		}
+	}
+
+	return nil
+}
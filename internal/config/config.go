@@ -1,23 +1,98 @@
 package config
 
 import (
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/audit"
 	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/authentik"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/denylist"
 	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/httpclient"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/metrics"
 )
 
 type Config struct {
 	// The address of the Authentik server to forward requests to.
-	Address string `json:"address"`
+	Address string `json:"address,omitempty"`
 
-	// The duration to cache the Authentik session responses.
+	// List of addresses of Authentik servers, used instead of address.
+	Addresses []string `json:"addresses,omitempty"`
+
+	// The strategy used to select an address when several are configured.
+	AddressStrategy string `json:"addressStrategy,omitempty"`
+
+	// The duration a failed address is skipped before being retried.
+	AddressCooldown string `json:"addressCooldown,omitempty"`
+
+	// The duration to cache the authenticated Authentik session responses.
 	CacheDuration string `json:"cacheDuration,omitempty"`
 
+	// The duration to cache the unauthenticated Authentik session responses.
+	CacheNegativeDuration string `json:"cacheNegativeDuration,omitempty"`
+
+	// The window before cache expiration in which sessions are revalidated in background.
+	CacheRevalidateWindow string `json:"cacheRevalidateWindow,omitempty"`
+
+	// The grace period in which expired authenticated sessions are served when Authentik fails.
+	CacheStaleIfError string `json:"cacheStaleIfError,omitempty"`
+
+	// Cap the cache duration to the lifetime allowed by the Authentik response headers.
+	CacheRespectHeaders bool `json:"cacheRespectHeaders,omitempty"`
+
+	// Share the cache with every middleware instance with identical Authentik and cache settings.
+	CacheShared bool `json:"cacheShared,omitempty"`
+
+	// The cached sessions deleted when a user signs out: the signed out session, or every session of the user on the host or on any host.
+	CacheSignOutScope string `json:"cacheSignOutScope,omitempty"`
+
+	// The backend storing the cached Authentik session responses.
+	CacheBackend string `json:"cacheBackend,omitempty"`
+
+	// Redis configuration, used when the cache backend is redis.
+	CacheRedis CacheRedisConfig `json:"cacheRedis,omitempty"`
+
+	// Snapshot configuration, used to restore the memory cache across restarts.
+	CacheSnapshot CacheSnapshotConfig `json:"cacheSnapshot,omitempty"`
+
+	// The maximum number of Authentik session responses kept in the cache.
+	CacheMaxEntries int `json:"cacheMaxEntries,omitempty"`
+
+	// The maximum approximate size in bytes of the cached session responses.
+	CacheMaxBytes int64 `json:"cacheMaxBytes,omitempty"`
+
+	// The number of consecutive Authentik failures that open the circuit breaker.
+	CircuitBreakerThreshold uint16 `json:"circuitBreakerThreshold,omitempty"`
+
+	// The duration the circuit breaker stays open before probing Authentik again.
+	CircuitBreakerCooldown string `json:"circuitBreakerCooldown,omitempty"`
+
+	// The number of times a failed Authentik check is retried.
+	RetryAttempts uint16 `json:"retryAttempts,omitempty"`
+
+	// The initial backoff duration between retries, doubled on every attempt.
+	RetryBackoff string `json:"retryBackoff,omitempty"`
+
+	// The maximum backoff duration between retries.
+	RetryMaxBackoff string `json:"retryMaxBackoff,omitempty"`
+
+	// The path of the endpoint receiving Authentik notifications to invalidate cached sessions.
+	WebhookPath string `json:"webhookPath,omitempty"`
+
+	// The secret Authentik notifications must carry to be accepted.
+	WebhookSecret string `json:"webhookSecret,omitempty"`
+
+	// Tracing configuration, used to export spans of Authentik requests.
+	Tracing TracingConfig `json:"tracing,omitempty"`
+
 	// The status code to return when the request is unauthorized.
 	UnauthorizedStatusCode uint16 `json:"unauthorizedStatusCode,omitempty"`
 
 	// The status code to return when unauthorized requests must be redirected.
 	RedirectStatusCode uint16 `json:"redirectStatusCode,omitempty"`
 
+	// The status code to return when the user is not in the required groups.
+	ForbiddenStatusCode uint16 `json:"forbiddenStatusCode,omitempty"`
+
+	// Ordered list of rules deciding how requests are handled, first match wins.
+	Rules []RuleConfig `json:"rules,omitempty"`
+
 	// List of path regexes that wont be checked for authentication.
 	SkippedPaths []string `json:"skippedPaths,omitempty"`
 
@@ -27,6 +102,33 @@ type Config struct {
 	// List of path regexes that will be treated as redirections.
 	RedirectPaths []string `json:"redirectPaths,omitempty"`
 
+	// List of path regexes that require the user to be in some groups.
+	GroupPaths []GroupPathConfig `json:"groupPaths,omitempty"`
+
+	// Send CORS preflight requests to upstream without authentication.
+	CORSPreflight bool `json:"corsPreflight,omitempty"`
+
+	// List of origins whose CORS preflight requests are sent to upstream.
+	CORSAllowedOrigins []string `json:"corsAllowedOrigins,omitempty"`
+
+	// The path serving the plugin metrics in Prometheus text format
+	MetricsPath string `json:"metricsPath,omitempty"`
+
+	// List of IP addresses or CIDR ranges allowed to read the metrics
+	MetricsAllowedIPs []string `json:"metricsAllowedIPs,omitempty"`
+
+	// The bearer token required to read the metrics
+	MetricsToken string `json:"metricsToken,omitempty"`
+
+	// Audit log configuration, used to record authentication decisions.
+	AuditLog AuditLogConfig `json:"auditLog,omitempty"`
+
+	// Path to a file listing denied user ids, usernames and groups
+	DenylistFile string `json:"denylistFile,omitempty"`
+
+	// Interval to check the denylist file for changes as a string (e.g., "5s")
+	DenylistInterval string `json:"denylistInterval,omitempty"`
+
 	// Connection timeout duration as a string (e.g., "30s", "1m")
 	Timeout string `json:"timeout,omitempty"`
 
@@ -34,6 +136,114 @@ type Config struct {
 	TLS TLSConfig `json:"tls,omitempty"`
 }
 
+type CacheRedisConfig struct {
+	// The address of the Redis server, as host:port
+	Address string `json:"address,omitempty"`
+
+	// The password used to authenticate to the Redis server
+	Password string `json:"password,omitempty"`
+
+	// The Redis database number
+	Database uint16 `json:"database,omitempty"`
+
+	// The prefix prepended to every cache key
+	Prefix string `json:"prefix,omitempty"`
+
+	// The secret used to encrypt the stored sessions
+	EncryptionKey string `json:"encryptionKey,omitempty"`
+
+	// Connection and command timeout duration as a string (e.g., "1s")
+	Timeout string `json:"timeout,omitempty"`
+}
+
+type CacheSnapshotConfig struct {
+	// Path to the snapshot file
+	File string `json:"file,omitempty"`
+
+	// Interval to write the snapshot as a string (e.g., "1m")
+	Interval string `json:"interval,omitempty"`
+
+	// The secret used to encrypt the snapshot
+	EncryptionKey string `json:"encryptionKey,omitempty"`
+}
+
+type RuleConfig struct {
+	// The name of the rule reported in metrics and audit logs
+	Name string `json:"name,omitempty"`
+
+	// The request path regex
+	Path string `json:"path,omitempty"`
+
+	// List of request host patterns (example.com, *.example.com, ~regex)
+	Hosts []string `json:"hosts,omitempty"`
+
+	// List of request methods
+	Methods []string `json:"methods,omitempty"`
+
+	// Request header regexes, by header name
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Request query parameter regexes, by parameter name
+	Query map[string]string `json:"query,omitempty"`
+
+	// The action taken on matching requests (skip, allow, deny, redirect)
+	Action string `json:"action,omitempty"`
+
+	// The status code returned by deny and redirect actions
+	StatusCode uint16 `json:"statusCode,omitempty"`
+
+	// List of groups authenticated users must be a member of
+	Groups []string `json:"groups,omitempty"`
+
+	// Whether the user must be in any or all of the groups (any, all)
+	GroupsMatch string `json:"groupsMatch,omitempty"`
+}
+
+type GroupPathConfig struct {
+	// The path regex the rule applies to
+	Path string `json:"path,omitempty"`
+
+	// List of host patterns the rule applies to (example.com, *.example.com, ~regex)
+	Hosts []string `json:"hosts,omitempty"`
+
+	// List of groups the user must be a member of
+	Groups []string `json:"groups,omitempty"`
+
+	// Whether the user must be in any or all of the groups (any, all)
+	Match string `json:"match,omitempty"`
+}
+
+type TracingConfig struct {
+	// The OTLP/HTTP traces endpoint of the collector
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// The service name reported in exported spans
+	ServiceName string `json:"serviceName,omitempty"`
+
+	// Headers sent with every export request (e.g., authentication)
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Interval to export spans as a string (e.g., "5s")
+	Interval string `json:"interval,omitempty"`
+
+	// Export request timeout duration as a string (e.g., "5s")
+	Timeout string `json:"timeout,omitempty"`
+
+	// The fraction of requests without a traceparent header that start a new trace, between 0 and 1
+	RootSampleRate float64 `json:"rootSampleRate,omitempty"`
+}
+
+type AuditLogConfig struct {
+	// The audit log output, either stdout or a file path
+	Output string `json:"output,omitempty"`
+
+	// The fraction of requests to record, between 0 and 1
+	SampleRate float64 `json:"sampleRate,omitempty"`
+
+	// List of fields to redact (host, path, username, uid)
+	Redact []string `json:"redact,omitempty"`
+}
+
 type TLSConfig struct {
 	// Path to the CA certificate file
 	CA string `json:"ca,omitempty"`
@@ -57,4 +267,7 @@ type TLSConfig struct {
 type PluginConfig struct {
 	Authentik  *authentik.Config
 	HTTPClient *httpclient.Config
+	Denylist   *denylist.Config
+	Metrics    *metrics.Config
+	AuditLog   *audit.Config
 }
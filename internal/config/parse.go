@@ -4,12 +4,22 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"regexp"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/audit"
 	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/authentik"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/denylist"
 	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/httpclient"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/metrics"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/redis"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/session"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/tracing"
 )
 
 const (
@@ -18,10 +28,47 @@ const (
 )
 
 const (
-	DefaultCacheDuration = "0s"
+	DefaultAddressStrategy = authentik.StrategyFailover
+	DefaultAddressCooldown = "10s"
+
+	DefaultCacheDuration         = "0s"
+	DefaultCacheRevalidateWindow = "0s"
+	DefaultCacheStaleIfError     = "0s"
+	DefaultCacheRespectHeaders   = false
+	DefaultCacheShared           = false
+	DefaultCacheSignOutScope     = authentik.SignOutScopeUser
+	DefaultCacheMaxEntries       = 10000
+	DefaultCacheMaxBytes         = 32 * 1024 * 1024
+
+	DefaultCacheSnapshotInterval = "1m"
+
+	DefaultCacheBackend      = session.BackendMemory
+	DefaultCacheRedisPrefix  = "traefik-authentik:"
+	DefaultCacheRedisTimeout = "1s"
+
+	DefaultCircuitBreakerThreshold = 0
+	DefaultCircuitBreakerCooldown  = "30s"
+
+	DefaultRetryAttempts   = 0
+	DefaultRetryBackoff    = "100ms"
+	DefaultRetryMaxBackoff = "2s"
+
+	DefaultTracingServiceName    = "traefik-authentik-forward-plugin"
+	DefaultTracingInterval       = "5s"
+	DefaultTracingTimeout        = "10s"
+	DefaultTracingRootSampleRate = 0
 
 	DefaultUnauthorizedStatusCode = http.StatusUnauthorized
 	DefaultRedirectStatusCode     = http.StatusFound
+	DefaultForbiddenStatusCode    = http.StatusForbidden
+
+	DefaultGroupsMatch = authentik.GroupMatchAny
+
+	DefaultCORSPreflight = false
+
+	DefaultDenylistInterval = "5s"
+
+	DefaultAuditLogSampleRate = 1
 
 	DefaultTimeout               = "0s"
 	DefaultTLSMinVersion         = 12
@@ -40,6 +87,9 @@ func (c *Config) Parse() (*PluginConfig, error) {
 	var err error
 	var authentikCfg *authentik.Config
 	var httpClientCfg *httpclient.Config
+	var denylistCfg *denylist.Config
+	var metricsCfg *metrics.Config
+	var auditLogCfg *audit.Config
 
 	authentikCfg, err = parseAuthentikConfig(c)
 	if err != nil {
@@ -51,20 +101,85 @@ func (c *Config) Parse() (*PluginConfig, error) {
 		return nil, fmt.Errorf("%w: %w", ErrConfigParse, err)
 	}
 
+	denylistCfg, err = parseDenylistConfig(c)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConfigParse, err)
+	}
+
+	metricsCfg, err = parseMetricsConfig(c)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConfigParse, err)
+	}
+
+	auditLogCfg, err = parseAuditLogConfig(c)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConfigParse, err)
+	}
+
+	// shared caches are only shared by instances with the same http client
+	authentikCfg.HTTPClient = httpClientCfg
+
 	return &PluginConfig{
 		Authentik:  authentikCfg,
 		HTTPClient: httpClientCfg,
+		Denylist:   denylistCfg,
+		Metrics:    metricsCfg,
+		AuditLog:   auditLogCfg,
 	}, nil
 }
 
 func parseAuthentikConfig(c *Config) (*authentik.Config, error) {
 	cfg := &authentik.Config{}
 
-	// parse authentik address
-	if c.Address == "" {
+	// parse authentik addresses
+	addresses := c.Addresses
+	if c.Address != "" {
+		if len(c.Addresses) > 0 {
+			return nil, errors.New("address and addresses cannot be both set")
+		}
+
+		if strings.Contains(c.Address, ",") {
+			return nil, errors.New("address must be a single address, use addresses for a list")
+		}
+
+		addresses = []string{c.Address}
+	}
+
+	for idx, address := range addresses {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			return nil, fmt.Errorf("addresses[%d] is empty", idx)
+		}
+
+		cfg.Addresses = append(cfg.Addresses, strings.TrimSuffix(address, "/"))
+	}
+
+	if len(cfg.Addresses) == 0 {
 		return nil, fmt.Errorf("%w: address is required", ErrConfigParse)
 	}
-	cfg.Address = c.Address
+
+	// parse authentik address strategy
+	if c.AddressStrategy == "" {
+		c.AddressStrategy = DefaultAddressStrategy
+	}
+
+	switch c.AddressStrategy {
+	case authentik.StrategyFailover, authentik.StrategyRoundRobin:
+		cfg.AddressStrategy = c.AddressStrategy
+	default:
+		return nil, fmt.Errorf("addressStrategy is not valid: %s", c.AddressStrategy)
+	}
+
+	// parse authentik address cooldown
+	if c.AddressCooldown == "" {
+		c.AddressCooldown = DefaultAddressCooldown
+	}
+
+	if addressCooldown, err := time.ParseDuration(c.AddressCooldown); err != nil {
+		return nil, fmt.Errorf("addressCooldown is not valid: %w", err)
+	} else {
+		cfg.AddressCooldown = addressCooldown
+	}
 
 	// parse cache duration
 	if c.CacheDuration == "" {
@@ -77,6 +192,173 @@ func parseAuthentikConfig(c *Config) (*authentik.Config, error) {
 		cfg.CacheDuration = cacheDuration
 	}
 
+	// parse cache negative duration, defaulting to the cache duration
+	if c.CacheNegativeDuration == "" {
+		c.CacheNegativeDuration = c.CacheDuration
+	}
+
+	if cacheNegativeDuration, err := time.ParseDuration(c.CacheNegativeDuration); err != nil {
+		return nil, fmt.Errorf("cacheNegativeDuration is not valid: %w", err)
+	} else {
+		cfg.CacheNegativeDuration = cacheNegativeDuration
+	}
+
+	// parse cache revalidate window
+	if c.CacheRevalidateWindow == "" {
+		c.CacheRevalidateWindow = DefaultCacheRevalidateWindow
+	}
+
+	if cacheRevalidateWindow, err := time.ParseDuration(c.CacheRevalidateWindow); err != nil {
+		return nil, fmt.Errorf("cacheRevalidateWindow is not valid: %w", err)
+	} else {
+		cfg.CacheRevalidateWindow = cacheRevalidateWindow
+	}
+
+	if cfg.CacheRevalidateWindow < 0 {
+		return nil, errors.New("cacheRevalidateWindow cannot be negative")
+	}
+
+	if cfg.CacheRevalidateWindow > 0 && cfg.CacheRevalidateWindow >= cfg.CacheDuration {
+		// every cache hit would start a background revalidation
+		return nil, errors.New("cacheRevalidateWindow must be shorter than cacheDuration")
+	}
+
+	// parse cache stale if error
+	if c.CacheStaleIfError == "" {
+		c.CacheStaleIfError = DefaultCacheStaleIfError
+	}
+
+	if cacheStaleIfError, err := time.ParseDuration(c.CacheStaleIfError); err != nil {
+		return nil, fmt.Errorf("cacheStaleIfError is not valid: %w", err)
+	} else {
+		cfg.CacheStaleIfError = cacheStaleIfError
+	}
+
+	// parse cache respect headers
+	cfg.CacheRespectHeaders = c.CacheRespectHeaders
+
+	// parse cache shared
+	cfg.CacheShared = c.CacheShared
+
+	// parse cache sign out scope
+	if c.CacheSignOutScope == "" {
+		c.CacheSignOutScope = DefaultCacheSignOutScope
+	}
+
+	switch c.CacheSignOutScope {
+	case authentik.SignOutScopeSession, authentik.SignOutScopeHost, authentik.SignOutScopeUser:
+		cfg.CacheSignOutScope = c.CacheSignOutScope
+	default:
+		return nil, fmt.Errorf("cacheSignOutScope is not valid: %s", c.CacheSignOutScope)
+	}
+
+	// parse cache backend
+	if c.CacheBackend == "" {
+		c.CacheBackend = DefaultCacheBackend
+	}
+
+	switch c.CacheBackend {
+	case session.BackendMemory:
+		cfg.CacheBackend = c.CacheBackend
+	case session.BackendRedis:
+		cfg.CacheBackend = c.CacheBackend
+
+		if err := parseCacheRedisConfig(c, cfg); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("cacheBackend is not valid: %s", c.CacheBackend)
+	}
+
+	// parse cache snapshot
+	if c.CacheSnapshot.File != "" {
+		if err := parseCacheSnapshotConfig(c, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	// parse cache max entries
+	if c.CacheMaxEntries == 0 {
+		c.CacheMaxEntries = DefaultCacheMaxEntries
+	} else if c.CacheMaxEntries < 0 {
+		return nil, errors.New("cacheMaxEntries cannot be negative")
+	}
+
+	cfg.CacheMaxEntries = c.CacheMaxEntries
+
+	// parse cache max bytes
+	if c.CacheMaxBytes == 0 {
+		c.CacheMaxBytes = DefaultCacheMaxBytes
+	} else if c.CacheMaxBytes < 0 {
+		return nil, errors.New("cacheMaxBytes cannot be negative")
+	}
+
+	cfg.CacheMaxBytes = c.CacheMaxBytes
+
+	// parse circuit breaker threshold
+	cfg.BreakerThreshold = int(c.CircuitBreakerThreshold)
+
+	// parse circuit breaker cooldown
+	if c.CircuitBreakerCooldown == "" {
+		c.CircuitBreakerCooldown = DefaultCircuitBreakerCooldown
+	}
+
+	if breakerCooldown, err := time.ParseDuration(c.CircuitBreakerCooldown); err != nil {
+		return nil, fmt.Errorf("circuitBreakerCooldown is not valid: %w", err)
+	} else {
+		cfg.BreakerCooldown = breakerCooldown
+	}
+
+	// parse retry attempts
+	cfg.RetryAttempts = int(c.RetryAttempts)
+
+	// parse retry backoff
+	if c.RetryBackoff == "" {
+		c.RetryBackoff = DefaultRetryBackoff
+	}
+
+	if retryBackoff, err := time.ParseDuration(c.RetryBackoff); err != nil {
+		return nil, fmt.Errorf("retryBackoff is not valid: %w", err)
+	} else {
+		cfg.RetryBackoff = retryBackoff
+	}
+
+	// parse retry max backoff
+	if c.RetryMaxBackoff == "" {
+		c.RetryMaxBackoff = DefaultRetryMaxBackoff
+	}
+
+	if retryMaxBackoff, err := time.ParseDuration(c.RetryMaxBackoff); err != nil {
+		return nil, fmt.Errorf("retryMaxBackoff is not valid: %w", err)
+	} else {
+		cfg.RetryMaxBackoff = retryMaxBackoff
+	}
+
+	if cfg.RetryBackoff > cfg.RetryMaxBackoff {
+		return nil, errors.New("retryBackoff cannot be higher than retryMaxBackoff")
+	}
+
+	// parse webhook path and secret
+	if c.WebhookPath != "" {
+		if !strings.HasPrefix(c.WebhookPath, "/") {
+			return nil, errors.New("webhookPath must start with /")
+		}
+
+		if strings.TrimSpace(c.WebhookSecret) == "" {
+			return nil, errors.New("webhookSecret is required when webhookPath is set")
+		}
+	}
+
+	cfg.WebhookPath = c.WebhookPath
+	cfg.WebhookSecret = c.WebhookSecret
+
+	// parse tracing
+	if c.Tracing.Endpoint != "" {
+		if err := parseTracingConfig(c, cfg); err != nil {
+			return nil, err
+		}
+	}
+
 	// set default unauthorized status code
 	if c.UnauthorizedStatusCode == 0 {
 		c.UnauthorizedStatusCode = DefaultUnauthorizedStatusCode
@@ -91,30 +373,366 @@ func parseAuthentikConfig(c *Config) (*authentik.Config, error) {
 
 	cfg.RedirectStatusCode = int(c.RedirectStatusCode)
 
-	// parse skipped paths
-	if skippedPaths, err := parsePathRegexes("skippedPaths", c.SkippedPaths); err != nil {
-		return nil, err
-	} else {
-		cfg.SkippedPaths = skippedPaths
+	// set default forbidden status code
+	if c.ForbiddenStatusCode == 0 {
+		c.ForbiddenStatusCode = DefaultForbiddenStatusCode
 	}
 
-	// parse unauthorized paths
-	if unauthorizedPaths, err := parsePathRegexes("unauthorizedPaths", c.UnauthorizedPaths); err != nil {
+	cfg.ForbiddenStatusCode = int(c.ForbiddenStatusCode)
+
+	// parse rules
+	if rules, err := parseRules(c.Rules, cfg); err != nil {
 		return nil, err
 	} else {
-		cfg.UnauthorizedPaths = unauthorizedPaths
+		cfg.Rules = rules
 	}
 
-	// parse redirect paths
-	if redirectPaths, err := parsePathRegexes("redirectPaths", c.RedirectPaths); err != nil {
+	// parse legacy path lists, evaluated after the rules
+	if rules, err := parseLegacyRules(c, cfg); err != nil {
 		return nil, err
 	} else {
-		cfg.RedirectPaths = redirectPaths
+		cfg.Rules = append(cfg.Rules, rules...)
+	}
+
+	// parse cors preflight
+	cfg.CORSPreflight = c.CORSPreflight
+
+	for idx, origin := range c.CORSAllowedOrigins {
+		if !c.CORSPreflight {
+			return nil, errors.New("corsAllowedOrigins requires corsPreflight")
+		}
+
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return nil, fmt.Errorf("corsAllowedOrigins[%d] is not a valid origin: %s", idx, origin)
+		}
+
+		cfg.CORSAllowedOrigins = append(cfg.CORSAllowedOrigins, strings.ToLower(u.Scheme+"://"+u.Host))
 	}
 
 	return cfg, nil
 }
 
+func parseCacheSnapshotConfig(c *Config, cfg *authentik.Config) error {
+	if cfg.CacheBackend != session.BackendMemory {
+		return errors.New("cacheSnapshot is only supported by the memory cache backend")
+	}
+
+	// parse snapshot encryption key
+	if c.CacheSnapshot.EncryptionKey == "" {
+		return errors.New("cacheSnapshot.encryptionKey is required")
+	}
+
+	cfg.CacheSnapshotFile = c.CacheSnapshot.File
+	cfg.CacheEncryptionKey = c.CacheSnapshot.EncryptionKey
+
+	// parse snapshot interval
+	if c.CacheSnapshot.Interval == "" {
+		c.CacheSnapshot.Interval = DefaultCacheSnapshotInterval
+	}
+
+	interval, err := time.ParseDuration(c.CacheSnapshot.Interval)
+	if err != nil {
+		return fmt.Errorf("cacheSnapshot.interval is not valid: %w", err)
+	}
+
+	cfg.CacheSnapshotInterval = interval
+
+	return nil
+}
+
+func parseCacheRedisConfig(c *Config, cfg *authentik.Config) error {
+	// parse redis address
+	if c.CacheRedis.Address == "" {
+		return errors.New("cacheRedis.address is required")
+	}
+
+	// parse redis timeout
+	if c.CacheRedis.Timeout == "" {
+		c.CacheRedis.Timeout = DefaultCacheRedisTimeout
+	}
+
+	timeout, err := time.ParseDuration(c.CacheRedis.Timeout)
+	if err != nil {
+		return fmt.Errorf("cacheRedis.timeout is not valid: %w", err)
+	}
+
+	cfg.CacheRedis = &redis.Config{
+		Address:  c.CacheRedis.Address,
+		Password: c.CacheRedis.Password,
+		Database: int(c.CacheRedis.Database),
+		Timeout:  timeout,
+	}
+
+	// parse redis key prefix
+	if c.CacheRedis.Prefix == "" {
+		c.CacheRedis.Prefix = DefaultCacheRedisPrefix
+	}
+
+	cfg.CacheRedisPrefix = c.CacheRedis.Prefix
+
+	// parse redis encryption key
+	cfg.CacheEncryptionKey = c.CacheRedis.EncryptionKey
+
+	return nil
+}
+
+func parseTracingConfig(c *Config, cfg *authentik.Config) error {
+	// parse tracing endpoint
+	endpoint, err := url.Parse(c.Tracing.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return fmt.Errorf("tracing.endpoint is not a valid http url: %s", c.Tracing.Endpoint)
+	}
+
+	// parse tracing service name
+	if c.Tracing.ServiceName == "" {
+		c.Tracing.ServiceName = DefaultTracingServiceName
+	}
+
+	// parse tracing interval
+	if c.Tracing.Interval == "" {
+		c.Tracing.Interval = DefaultTracingInterval
+	}
+
+	interval, err := time.ParseDuration(c.Tracing.Interval)
+	if err != nil {
+		return fmt.Errorf("tracing.interval is not valid: %w", err)
+	}
+
+	if interval <= 0 {
+		return errors.New("tracing.interval must be greater than 0")
+	}
+
+	// parse tracing timeout
+	if c.Tracing.Timeout == "" {
+		c.Tracing.Timeout = DefaultTracingTimeout
+	}
+
+	timeout, err := time.ParseDuration(c.Tracing.Timeout)
+	if err != nil {
+		return fmt.Errorf("tracing.timeout is not valid: %w", err)
+	}
+
+	// parse tracing root sample rate
+	if c.Tracing.RootSampleRate < 0 || c.Tracing.RootSampleRate > 1 {
+		return errors.New("tracing.rootSampleRate must be between 0 and 1")
+	}
+
+	cfg.Tracing = &tracing.Config{
+		Endpoint:       c.Tracing.Endpoint,
+		ServiceName:    c.Tracing.ServiceName,
+		Headers:        c.Tracing.Headers,
+		Interval:       interval,
+		Timeout:        timeout,
+		RootSampleRate: c.Tracing.RootSampleRate,
+	}
+
+	return nil
+}
+
+func parseRules(rules []RuleConfig, cfg *authentik.Config) ([]*authentik.Rule, error) {
+	parsed := make([]*authentik.Rule, 0, len(rules))
+	for idx, r := range rules {
+		rule := &authentik.Rule{
+			Name:    r.Name,
+			Methods: r.Methods,
+		}
+
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rules[%d]", idx)
+		}
+
+		var err error
+
+		// parse path and host matchers
+		if rule.Path, err = parseOptionalRegex(r.Path); err != nil {
+			return nil, fmt.Errorf("rules[%d].path is not valid: %w", idx, err)
+		}
+
+		if rule.Hosts, err = parseHostPatterns(r.Hosts); err != nil {
+			return nil, fmt.Errorf("rules[%d].hosts is not valid: %w", idx, err)
+		}
+
+		// parse header and query matchers
+		if rule.Headers, err = parseRegexMap(r.Headers); err != nil {
+			return nil, fmt.Errorf("rules[%d].headers is not valid: %w", idx, err)
+		}
+
+		if rule.Query, err = parseRegexMap(r.Query); err != nil {
+			return nil, fmt.Errorf("rules[%d].query is not valid: %w", idx, err)
+		}
+
+		// parse action and status code
+		rule.Action = r.Action
+		rule.StatusCode = int(r.StatusCode)
+
+		switch r.Action {
+		case authentik.ActionSkip, authentik.ActionAllow:
+			if rule.StatusCode != 0 {
+				return nil, fmt.Errorf("rules[%d].statusCode is not supported by action %s", idx, r.Action)
+			}
+
+			rule.StatusCode = http.StatusOK
+		case authentik.ActionDeny:
+			if rule.StatusCode == 0 {
+				rule.StatusCode = cfg.UnauthorizedStatusCode
+			} else if rule.StatusCode < 400 || rule.StatusCode > 599 {
+				return nil, fmt.Errorf("rules[%d].statusCode must be a 4xx or 5xx code", idx)
+			}
+		case authentik.ActionRedirect:
+			if rule.StatusCode == 0 {
+				rule.StatusCode = cfg.RedirectStatusCode
+			} else if rule.StatusCode < 300 || rule.StatusCode > 399 {
+				return nil, fmt.Errorf("rules[%d].statusCode must be a 3xx code", idx)
+			}
+		case authentik.ActionNegotiate:
+			if rule.StatusCode != 0 {
+				return nil, fmt.Errorf("rules[%d].statusCode is not supported by action %s", idx, r.Action)
+			}
+
+			// the status code is chosen on each request
+			rule.StatusCode = cfg.UnauthorizedStatusCode
+		case "":
+			return nil, fmt.Errorf("rules[%d].action is required", idx)
+		default:
+			return nil, fmt.Errorf("rules[%d].action is not valid: %s", idx, r.Action)
+		}
+
+		// parse required groups
+		if len(r.Groups) > 0 && rule.StatusCode == http.StatusOK {
+			// anonymous users must never reach group protected requests
+			return nil, fmt.Errorf("rules[%d].groups is not supported by action %s", idx, r.Action)
+		}
+
+		rule.Groups = r.Groups
+
+		if rule.MatchAll, err = parseGroupsMatch(r.GroupsMatch); err != nil {
+			return nil, fmt.Errorf("rules[%d].groupsMatch is not valid: %w", idx, err)
+		}
+
+		parsed = append(parsed, rule)
+	}
+
+	return parsed, nil
+}
+
+// parseLegacyRules translates the skipped, group, unauthorized and redirect
+// path lists into equivalent rules, named after their path regexes.
+func parseLegacyRules(c *Config, cfg *authentik.Config) ([]*authentik.Rule, error) {
+	skippedPaths, err := parsePathRegexes("skippedPaths", c.SkippedPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	groupPaths, err := parseGroupPaths(c.GroupPaths, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	unauthorizedPaths, err := parsePathRegexes("unauthorizedPaths", c.UnauthorizedPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	redirectPaths, err := parsePathRegexes("redirectPaths", c.RedirectPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	// skipped paths have priority over every other path
+	rules := make([]*authentik.Rule, 0, len(skippedPaths)+len(groupPaths)+len(unauthorizedPaths)+len(redirectPaths))
+	for _, re := range skippedPaths {
+		rules = append(rules, &authentik.Rule{
+			Name:       re.String(),
+			Path:       re,
+			Action:     authentik.ActionSkip,
+			StatusCode: http.StatusOK,
+		})
+	}
+
+	rules = append(rules, groupPaths...)
+
+	// the longest unauthorized or redirect path wins, unauthorized paths first on ties
+	checked := make([]*authentik.Rule, 0, len(unauthorizedPaths)+len(redirectPaths))
+	for _, re := range unauthorizedPaths {
+		checked = append(checked, &authentik.Rule{
+			Name:       re.String(),
+			Path:       re,
+			Action:     authentik.ActionDeny,
+			StatusCode: cfg.UnauthorizedStatusCode,
+		})
+	}
+
+	for _, re := range redirectPaths {
+		checked = append(checked, &authentik.Rule{
+			Name:       re.String(),
+			Path:       re,
+			Action:     authentik.ActionRedirect,
+			StatusCode: cfg.RedirectStatusCode,
+		})
+	}
+
+	sort.SliceStable(checked, func(i, j int) bool {
+		return len(checked[i].Path.String()) > len(checked[j].Path.String())
+	})
+
+	return append(rules, checked...), nil
+}
+
+// parseGroupPaths translates the group paths into rules inheriting the action
+// of the matching unauthorized or redirect path, the longest path first.
+func parseGroupPaths(paths []GroupPathConfig, cfg *authentik.Config) ([]*authentik.Rule, error) {
+	rules := make([]*authentik.Rule, 0, len(paths))
+	for idx, path := range paths {
+		re, err := regexp.Compile(path.Path)
+		if err != nil {
+			return nil, fmt.Errorf("groupPaths[%d].path is not valid: %w", idx, err)
+		}
+
+		hosts, err := parseHostPatterns(path.Hosts)
+		if err != nil {
+			return nil, fmt.Errorf("groupPaths[%d].hosts is not valid: %w", idx, err)
+		}
+
+		if len(path.Groups) == 0 {
+			return nil, fmt.Errorf("groupPaths[%d].groups is required", idx)
+		}
+
+		matchAll, err := parseGroupsMatch(path.Match)
+		if err != nil {
+			return nil, fmt.Errorf("groupPaths[%d].match is not valid: %w", idx, err)
+		}
+
+		rules = append(rules, &authentik.Rule{
+			Name:       re.String(),
+			Path:       re,
+			Hosts:      hosts,
+			Action:     authentik.ActionInherit,
+			StatusCode: cfg.UnauthorizedStatusCode,
+			Groups:     path.Groups,
+			MatchAll:   matchAll,
+		})
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		return len(rules[i].Path.String()) > len(rules[j].Path.String())
+	})
+
+	return rules, nil
+}
+
+func parseGroupsMatch(match string) (bool, error) {
+	if match == "" {
+		match = DefaultGroupsMatch
+	}
+
+	if match != authentik.GroupMatchAny && match != authentik.GroupMatchAll {
+		return false, fmt.Errorf("unknown match mode %s", match)
+	}
+
+	return match == authentik.GroupMatchAll, nil
+}
+
 func parsePathRegexes(name string, paths []string) ([]*regexp.Regexp, error) {
 	pathRegexes := make([]*regexp.Regexp, 0, len(paths))
 	for idx, path := range paths {
@@ -129,6 +747,46 @@ func parsePathRegexes(name string, paths []string) ([]*regexp.Regexp, error) {
 	return pathRegexes, nil
 }
 
+func parseOptionalRegex(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	return regexp.Compile(expr)
+}
+
+func parseHostPatterns(patterns []string) ([]*authentik.HostPattern, error) {
+	hosts := make([]*authentik.HostPattern, 0, len(patterns))
+	for _, pattern := range patterns {
+		if pattern == "" || pattern == "~" || pattern == "*." {
+			return nil, errors.New("empty host pattern")
+		}
+
+		host, err := authentik.ParseHostPattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", pattern, err)
+		}
+
+		hosts = append(hosts, host)
+	}
+
+	return hosts, nil
+}
+
+func parseRegexMap(exprs map[string]string) (map[string]*regexp.Regexp, error) {
+	regexes := make(map[string]*regexp.Regexp, len(exprs))
+	for k, expr := range exprs {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+
+		regexes[k] = re
+	}
+
+	return regexes, nil
+}
+
 func parseHTTPClientConfig(c *Config) (*httpclient.Config, error) {
 	cfg := &httpclient.Config{}
 
@@ -181,3 +839,111 @@ func parseHTTPClientConfig(c *Config) (*httpclient.Config, error) {
 
 	return cfg, nil
 }
+
+func parseDenylistConfig(c *Config) (*denylist.Config, error) {
+	if c.DenylistFile == "" {
+		// denylist is disabled
+		return nil, nil //nolint:nilnil
+	}
+
+	cfg := &denylist.Config{
+		File: c.DenylistFile,
+	}
+
+	// parse denylist interval
+	if c.DenylistInterval == "" {
+		c.DenylistInterval = DefaultDenylistInterval
+	}
+
+	interval, err := time.ParseDuration(c.DenylistInterval)
+	if err != nil {
+		return nil, fmt.Errorf("denylistInterval is not valid: %w", err)
+	}
+
+	cfg.Interval = interval
+
+	return cfg, nil
+}
+
+func parseMetricsConfig(c *Config) (*metrics.Config, error) {
+	if c.MetricsPath == "" {
+		// metrics are disabled
+		return nil, nil //nolint:nilnil
+	}
+
+	// parse metrics path
+	if !strings.HasPrefix(c.MetricsPath, "/") {
+		return nil, errors.New("metricsPath must start with /")
+	}
+
+	cfg := &metrics.Config{
+		Path:  c.MetricsPath,
+		Token: c.MetricsToken,
+	}
+
+	// parse metrics allowed ips
+	for idx, ip := range c.MetricsAllowedIPs {
+		if !strings.Contains(ip, "/") {
+			// single addresses are converted to a single host range
+			if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil {
+				ip += "/32"
+			} else {
+				ip += "/128"
+			}
+		}
+
+		_, ipNet, err := net.ParseCIDR(ip)
+		if err != nil {
+			return nil, fmt.Errorf("metricsAllowedIPs[%d] is not valid: %w", idx, err)
+		}
+
+		cfg.AllowedIPs = append(cfg.AllowedIPs, ipNet)
+	}
+
+	if len(cfg.AllowedIPs) == 0 && cfg.Token == "" {
+		return nil, errors.New("metricsAllowedIPs or metricsToken is required when metricsPath is set")
+	}
+
+	return cfg, nil
+}
+
+func parseAuditLogConfig(c *Config) (*audit.Config, error) {
+	if c.AuditLog.Output == "" {
+		// audit log is disabled
+		return nil, nil //nolint:nilnil
+	}
+
+	cfg := &audit.Config{
+		Output: c.AuditLog.Output,
+	}
+
+	// parse audit log sample rate
+	if c.AuditLog.SampleRate == 0 {
+		c.AuditLog.SampleRate = DefaultAuditLogSampleRate
+	} else if c.AuditLog.SampleRate < 0 || c.AuditLog.SampleRate > 1 {
+		return nil, errors.New("auditLog.sampleRate must be between 0 and 1")
+	}
+
+	cfg.SampleRate = c.AuditLog.SampleRate
+
+	// parse audit log redacted fields
+	for idx, field := range c.AuditLog.Redact {
+		if !isRedactableField(field) {
+			return nil, fmt.Errorf("auditLog.redact[%d] is not valid: %s", idx, field)
+		}
+
+		cfg.Redact = append(cfg.Redact, field)
+	}
+
+	return cfg, nil
+}
+
+func isRedactableField(field string) bool {
+	for _, f := range audit.RedactableFields {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
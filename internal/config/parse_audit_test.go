@@ -0,0 +1,78 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/config"
+)
+
+func TestParse_AuditLog(t *testing.T) {
+	t.Run("with empty value", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if pc.AuditLog != nil {
+			t.Errorf("expected audit log to be disabled")
+		}
+	})
+
+	t.Run("with valid values", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+			AuditLog: config.AuditLogConfig{
+				Output: "stdout",
+				Redact: []string{"username"},
+			},
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expectedSampleRate := 1.0
+		if pc.AuditLog.SampleRate != expectedSampleRate {
+			t.Errorf("expected sample rate %v, got %v", expectedSampleRate, pc.AuditLog.SampleRate)
+		}
+
+		if len(pc.AuditLog.Redact) != 1 || pc.AuditLog.Redact[0] != "username" {
+			t.Errorf("expected redacted fields to be [username], got %v", pc.AuditLog.Redact)
+		}
+	})
+
+	t.Run("with invalid sample rate", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+			AuditLog: config.AuditLogConfig{
+				Output:     "stdout",
+				SampleRate: 1.5,
+			},
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for invalid sample rate, got none")
+		}
+	})
+
+	t.Run("with invalid redacted field", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+			AuditLog: config.AuditLogConfig{
+				Output: "stdout",
+				Redact: []string{"email"},
+			},
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for invalid redacted field, got none")
+		}
+	})
+}
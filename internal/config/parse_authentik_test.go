@@ -1,9 +1,12 @@
 package config_test
 
 import (
+	"errors"
+	"net/http"
 	"testing"
 	"time"
 
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/authentik"
 	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/config"
 )
 
@@ -53,3 +56,743 @@ func TestParse_CacheDuration(t *testing.T) {
 		}
 	})
 }
+
+func TestParse_CacheLimits(t *testing.T) {
+	t.Run("with empty values", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expectedMaxEntries := 10000
+		if pc.Authentik.CacheMaxEntries != expectedMaxEntries {
+			t.Errorf("expected cache max entries to be %d, got %d", expectedMaxEntries, pc.Authentik.CacheMaxEntries)
+		}
+
+		var expectedMaxBytes int64 = 32 * 1024 * 1024
+		if pc.Authentik.CacheMaxBytes != expectedMaxBytes {
+			t.Errorf("expected cache max bytes to be %d, got %d", expectedMaxBytes, pc.Authentik.CacheMaxBytes)
+		}
+	})
+
+	t.Run("with valid values", func(t *testing.T) {
+		config := config.Config{
+			Address:         "https://authentik.example.com",
+			CacheMaxEntries: 100,
+			CacheMaxBytes:   1024,
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expectedMaxEntries := 100
+		if pc.Authentik.CacheMaxEntries != expectedMaxEntries {
+			t.Errorf("expected cache max entries to be %d, got %d", expectedMaxEntries, pc.Authentik.CacheMaxEntries)
+		}
+
+		var expectedMaxBytes int64 = 1024
+		if pc.Authentik.CacheMaxBytes != expectedMaxBytes {
+			t.Errorf("expected cache max bytes to be %d, got %d", expectedMaxBytes, pc.Authentik.CacheMaxBytes)
+		}
+	})
+
+	t.Run("with negative values", func(t *testing.T) {
+		config := config.Config{
+			Address:         "https://authentik.example.com",
+			CacheMaxEntries: -1,
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for negative cache max entries, got none")
+		}
+	})
+}
+
+func TestParse_CacheNegativeDuration(t *testing.T) {
+	t.Run("with empty value", func(t *testing.T) {
+		config := config.Config{
+			Address:       "https://authentik.example.com",
+			CacheDuration: "1m",
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expectedCacheNegativeDuration := time.Minute
+		if pc.Authentik.CacheNegativeDuration != expectedCacheNegativeDuration {
+			t.Errorf("expected cache negative duration to be %v, got %v", expectedCacheNegativeDuration, pc.Authentik.CacheNegativeDuration)
+		}
+	})
+
+	t.Run("with zero value", func(t *testing.T) {
+		config := config.Config{
+			Address:               "https://authentik.example.com",
+			CacheDuration:         "1m",
+			CacheNegativeDuration: "0s",
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expectedCacheNegativeDuration := time.Duration(0)
+		if pc.Authentik.CacheNegativeDuration != expectedCacheNegativeDuration {
+			t.Errorf("expected cache negative duration to be %v, got %v", expectedCacheNegativeDuration, pc.Authentik.CacheNegativeDuration)
+		}
+	})
+
+	t.Run("with invalid value", func(t *testing.T) {
+		config := config.Config{
+			Address:               "https://authentik.example.com",
+			CacheNegativeDuration: "invalid",
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for invalid cache negative duration, got none")
+		}
+	})
+}
+
+func TestParse_CacheRevalidateWindow(t *testing.T) {
+	t.Run("with empty value", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expectedCacheRevalidateWindow := time.Duration(0)
+		if pc.Authentik.CacheRevalidateWindow != expectedCacheRevalidateWindow {
+			t.Errorf("expected cache revalidate window to be %v, got %v", expectedCacheRevalidateWindow, pc.Authentik.CacheRevalidateWindow)
+		}
+	})
+
+	t.Run("with valid value", func(t *testing.T) {
+		config := config.Config{
+			Address:               "https://authentik.example.com",
+			CacheDuration:         "1m",
+			CacheRevalidateWindow: "15s",
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expectedCacheRevalidateWindow := 15 * time.Second
+		if pc.Authentik.CacheRevalidateWindow != expectedCacheRevalidateWindow {
+			t.Errorf("expected cache revalidate window to be %v, got %v", expectedCacheRevalidateWindow, pc.Authentik.CacheRevalidateWindow)
+		}
+	})
+
+	t.Run("with invalid value", func(t *testing.T) {
+		config := config.Config{
+			Address:               "https://authentik.example.com",
+			CacheRevalidateWindow: "invalid",
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for invalid cache revalidate window, got none")
+		}
+	})
+
+	tests := []struct {
+		name                  string
+		cacheDuration         string
+		cacheRevalidateWindow string
+	}{
+		{"with negative value", "1m", "-15s"},
+		{"with value equal to cache duration", "1m", "1m"},
+		{"with value longer than cache duration", "1m", "2m"},
+		{"with cache disabled", "0s", "15s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Address:               "https://authentik.example.com",
+				CacheDuration:         tt.cacheDuration,
+				CacheRevalidateWindow: tt.cacheRevalidateWindow,
+			}
+
+			_, err := cfg.Parse()
+			if !errors.Is(err, config.ErrConfigParse) {
+				t.Errorf("expected error %q, got %v", config.ErrConfigParse, err)
+			}
+		})
+	}
+}
+
+func TestParse_CacheStaleIfError(t *testing.T) {
+	t.Run("with valid value", func(t *testing.T) {
+		config := config.Config{
+			Address:           "https://authentik.example.com",
+			CacheDuration:     "1m",
+			CacheStaleIfError: "5m",
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expectedCacheStaleIfError := 5 * time.Minute
+		if pc.Authentik.CacheStaleIfError != expectedCacheStaleIfError {
+			t.Errorf("expected cache stale if error to be %v, got %v", expectedCacheStaleIfError, pc.Authentik.CacheStaleIfError)
+		}
+	})
+
+	t.Run("with invalid value", func(t *testing.T) {
+		config := config.Config{
+			Address:           "https://authentik.example.com",
+			CacheStaleIfError: "invalid",
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for invalid cache stale if error, got none")
+		}
+	})
+}
+
+func TestParse_CircuitBreaker(t *testing.T) {
+	t.Run("with empty values", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expectedThreshold := 0
+		if pc.Authentik.BreakerThreshold != expectedThreshold {
+			t.Errorf("expected breaker threshold to be %d, got %d", expectedThreshold, pc.Authentik.BreakerThreshold)
+		}
+
+		expectedCooldown := 30 * time.Second
+		if pc.Authentik.BreakerCooldown != expectedCooldown {
+			t.Errorf("expected breaker cooldown to be %v, got %v", expectedCooldown, pc.Authentik.BreakerCooldown)
+		}
+	})
+
+	t.Run("with invalid cooldown", func(t *testing.T) {
+		config := config.Config{
+			Address:                "https://authentik.example.com",
+			CircuitBreakerCooldown: "invalid",
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for invalid circuit breaker cooldown, got none")
+		}
+	})
+}
+
+func TestParse_Retry(t *testing.T) {
+	t.Run("with empty values", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expectedBackoff := 100 * time.Millisecond
+		if pc.Authentik.RetryBackoff != expectedBackoff {
+			t.Errorf("expected retry backoff to be %v, got %v", expectedBackoff, pc.Authentik.RetryBackoff)
+		}
+
+		expectedMaxBackoff := 2 * time.Second
+		if pc.Authentik.RetryMaxBackoff != expectedMaxBackoff {
+			t.Errorf("expected retry max backoff to be %v, got %v", expectedMaxBackoff, pc.Authentik.RetryMaxBackoff)
+		}
+	})
+
+	t.Run("with backoff higher than max backoff", func(t *testing.T) {
+		config := config.Config{
+			Address:         "https://authentik.example.com",
+			RetryBackoff:    "5s",
+			RetryMaxBackoff: "1s",
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for retry backoff higher than max backoff, got none")
+		}
+	})
+}
+
+func TestParse_CacheBackend(t *testing.T) {
+	t.Run("with empty value", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if pc.Authentik.CacheBackend != "memory" {
+			t.Errorf("expected cache backend to be memory, got %s", pc.Authentik.CacheBackend)
+		}
+
+		if pc.Authentik.CacheRedis != nil {
+			t.Error("expected redis config to be nil")
+		}
+	})
+
+	t.Run("with redis backend", func(t *testing.T) {
+		config := config.Config{
+			Address:      "https://authentik.example.com",
+			CacheBackend: "redis",
+			CacheRedis: config.CacheRedisConfig{
+				Address:       "redis:6379",
+				Password:      "secret",
+				Database:      2,
+				EncryptionKey: "key",
+			},
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if pc.Authentik.CacheRedis == nil {
+			t.Fatal("expected redis config to be not nil")
+		}
+
+		if pc.Authentik.CacheRedis.Address != "redis:6379" {
+			t.Errorf("expected redis address to be redis:6379, got %s", pc.Authentik.CacheRedis.Address)
+		}
+
+		if pc.Authentik.CacheRedis.Database != 2 {
+			t.Errorf("expected redis database to be 2, got %d", pc.Authentik.CacheRedis.Database)
+		}
+
+		if pc.Authentik.CacheRedis.Timeout != time.Second {
+			t.Errorf("expected redis timeout to be 1s, got %v", pc.Authentik.CacheRedis.Timeout)
+		}
+
+		if pc.Authentik.CacheRedisPrefix != "traefik-authentik:" {
+			t.Errorf("expected redis prefix to be traefik-authentik:, got %s", pc.Authentik.CacheRedisPrefix)
+		}
+
+		if pc.Authentik.CacheEncryptionKey != "key" {
+			t.Errorf("expected encryption key to be key, got %s", pc.Authentik.CacheEncryptionKey)
+		}
+	})
+
+	t.Run("with redis backend and no address", func(t *testing.T) {
+		config := config.Config{
+			Address:      "https://authentik.example.com",
+			CacheBackend: "redis",
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for missing redis address, got none")
+		}
+	})
+
+	t.Run("with invalid value", func(t *testing.T) {
+		config := config.Config{
+			Address:      "https://authentik.example.com",
+			CacheBackend: "memcached",
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for invalid cache backend, got none")
+		}
+	})
+}
+
+func TestParse_CacheSignOutScope(t *testing.T) {
+	t.Run("with empty value", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if pc.Authentik.CacheSignOutScope != "user" {
+			t.Errorf("expected sign out scope to be user, got %s", pc.Authentik.CacheSignOutScope)
+		}
+	})
+
+	t.Run("with valid value", func(t *testing.T) {
+		config := config.Config{
+			Address:           "https://authentik.example.com",
+			CacheSignOutScope: "host",
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if pc.Authentik.CacheSignOutScope != "host" {
+			t.Errorf("expected sign out scope to be host, got %s", pc.Authentik.CacheSignOutScope)
+		}
+	})
+
+	t.Run("with invalid value", func(t *testing.T) {
+		config := config.Config{
+			Address:           "https://authentik.example.com",
+			CacheSignOutScope: "everyone",
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for invalid sign out scope, got none")
+		}
+	})
+}
+
+func TestParse_Webhook(t *testing.T) {
+	t.Run("with valid values", func(t *testing.T) {
+		config := config.Config{
+			Address:       "https://authentik.example.com",
+			WebhookPath:   "/_authentik/webhook",
+			WebhookSecret: "secret",
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if pc.Authentik.WebhookPath != "/_authentik/webhook" {
+			t.Errorf("expected webhook path to be /_authentik/webhook, got %s", pc.Authentik.WebhookPath)
+		}
+	})
+
+	t.Run("with relative path", func(t *testing.T) {
+		config := config.Config{
+			Address:       "https://authentik.example.com",
+			WebhookPath:   "_authentik/webhook",
+			WebhookSecret: "secret",
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for relative webhook path, got none")
+		}
+	})
+
+	t.Run("with no secret", func(t *testing.T) {
+		config := config.Config{
+			Address:     "https://authentik.example.com",
+			WebhookPath: "/_authentik/webhook",
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for missing webhook secret, got none")
+		}
+	})
+}
+
+func TestParse_CacheSnapshot(t *testing.T) {
+	t.Run("with valid values", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+			CacheSnapshot: config.CacheSnapshotConfig{
+				File:          "/var/lib/traefik/authentik.snapshot",
+				EncryptionKey: "secret",
+			},
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if pc.Authentik.CacheSnapshotFile != "/var/lib/traefik/authentik.snapshot" {
+			t.Errorf("expected snapshot file to be set, got %s", pc.Authentik.CacheSnapshotFile)
+		}
+
+		expectedInterval := time.Minute
+		if pc.Authentik.CacheSnapshotInterval != expectedInterval {
+			t.Errorf("expected snapshot interval %v, got %v", expectedInterval, pc.Authentik.CacheSnapshotInterval)
+		}
+	})
+
+	t.Run("with no encryption key", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+			CacheSnapshot: config.CacheSnapshotConfig{
+				File: "/var/lib/traefik/authentik.snapshot",
+			},
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for missing snapshot encryption key, got none")
+		}
+	})
+
+	t.Run("with redis backend", func(t *testing.T) {
+		config := config.Config{
+			Address:      "https://authentik.example.com",
+			CacheBackend: "redis",
+			CacheRedis:   config.CacheRedisConfig{Address: "redis:6379"},
+			CacheSnapshot: config.CacheSnapshotConfig{
+				File:          "/var/lib/traefik/authentik.snapshot",
+				EncryptionKey: "secret",
+			},
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for snapshot with redis backend, got none")
+		}
+	})
+}
+
+func TestParse_GroupPaths(t *testing.T) {
+	t.Run("with default values", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+			GroupPaths: []config.GroupPathConfig{
+				{Path: "^/admin", Groups: []string{"admins"}},
+			},
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(pc.Authentik.Rules) != 1 {
+			t.Fatalf("expected 1 rule, got %d", len(pc.Authentik.Rules))
+		}
+
+		rule := pc.Authentik.Rules[0]
+		if rule.Action != authentik.ActionInherit {
+			t.Errorf("expected rule action %s, got %s", authentik.ActionInherit, rule.Action)
+		}
+
+		if rule.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected rule status code %d, got %d", http.StatusUnauthorized, rule.StatusCode)
+		}
+
+		if rule.MatchAll {
+			t.Errorf("expected rule to match any group")
+		}
+
+		expectedStatusCode := http.StatusForbidden
+		if pc.Authentik.ForbiddenStatusCode != expectedStatusCode {
+			t.Errorf("expected forbidden status code %d, got %d", expectedStatusCode, pc.Authentik.ForbiddenStatusCode)
+		}
+	})
+
+	t.Run("with all match", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+			GroupPaths: []config.GroupPathConfig{
+				{Path: "^/admin", Groups: []string{"admins", "operators"}, Match: "all"},
+			},
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if !pc.Authentik.Rules[0].MatchAll {
+			t.Errorf("expected rule to match all groups")
+		}
+	})
+
+	t.Run("with legacy paths", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+			GroupPaths: []config.GroupPathConfig{
+				{Path: "^/admin", Groups: []string{"admins"}},
+				{Path: "^/admin/billing", Groups: []string{"billing"}},
+			},
+			SkippedPaths:      []string{"^/admin/health$"},
+			UnauthorizedPaths: []string{"^/.*$"},
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		// group paths come after skipped paths, the longest first
+		expectedRules := []string{"^/admin/health$", "^/admin/billing", "^/admin", "^/.*$"}
+		if len(pc.Authentik.Rules) != len(expectedRules) {
+			t.Fatalf("expected %d rules, got %d", len(expectedRules), len(pc.Authentik.Rules))
+		}
+
+		for idx, name := range expectedRules {
+			if pc.Authentik.Rules[idx].Name != name {
+				t.Errorf("expected rule %d name %s, got %s", idx, name, pc.Authentik.Rules[idx].Name)
+			}
+		}
+	})
+
+	tests := []struct {
+		name      string
+		groupPath config.GroupPathConfig
+	}{
+		{"with invalid path", config.GroupPathConfig{Path: "^/admin(", Groups: []string{"admins"}}},
+		{"with no groups", config.GroupPathConfig{Path: "^/admin"}},
+		{"with invalid match", config.GroupPathConfig{Path: "^/admin", Groups: []string{"admins"}, Match: "some"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := config.Config{
+				Address:    "https://authentik.example.com",
+				GroupPaths: []config.GroupPathConfig{tt.groupPath},
+			}
+
+			_, err := config.Parse()
+			if err == nil {
+				t.Fatal("expected error, got none")
+			}
+		})
+	}
+}
+
+func TestParse_CORSPreflight(t *testing.T) {
+	t.Run("with allowed origins", func(t *testing.T) {
+		config := config.Config{
+			Address:            "https://authentik.example.com",
+			CORSPreflight:      true,
+			CORSAllowedOrigins: []string{"https://App.example.com/", "http://localhost:3000"},
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expectedOrigins := []string{"https://app.example.com", "http://localhost:3000"}
+		if len(pc.Authentik.CORSAllowedOrigins) != len(expectedOrigins) {
+			t.Fatalf("expected %d origins, got %d", len(expectedOrigins), len(pc.Authentik.CORSAllowedOrigins))
+		}
+
+		for idx, origin := range expectedOrigins {
+			if pc.Authentik.CORSAllowedOrigins[idx] != origin {
+				t.Errorf("expected origin %s, got %s", origin, pc.Authentik.CORSAllowedOrigins[idx])
+			}
+		}
+	})
+
+	t.Run("with invalid origin", func(t *testing.T) {
+		config := config.Config{
+			Address:            "https://authentik.example.com",
+			CORSPreflight:      true,
+			CORSAllowedOrigins: []string{"https://app.example.com/path"},
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for invalid origin, got none")
+		}
+	})
+
+	t.Run("with allowed origins and preflight disabled", func(t *testing.T) {
+		config := config.Config{
+			Address:            "https://authentik.example.com",
+			CORSAllowedOrigins: []string{"https://app.example.com"},
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for allowed origins without preflight, got none")
+		}
+	})
+}
+
+func TestParse_Addresses(t *testing.T) {
+	t.Run("with single address", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com/",
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(pc.Authentik.Addresses) != 1 || pc.Authentik.Addresses[0] != "https://authentik.example.com" {
+			t.Errorf("expected addresses [https://authentik.example.com], got %v", pc.Authentik.Addresses)
+		}
+	})
+
+	t.Run("with address list", func(t *testing.T) {
+		config := config.Config{
+			Addresses: []string{"https://auth1.example.com", "https://auth2.example.com/"},
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expected := []string{"https://auth1.example.com", "https://auth2.example.com"}
+		if len(pc.Authentik.Addresses) != len(expected) {
+			t.Fatalf("expected %d addresses, got %d", len(expected), len(pc.Authentik.Addresses))
+		}
+
+		for idx, address := range expected {
+			if pc.Authentik.Addresses[idx] != address {
+				t.Errorf("expected address %s, got %s", address, pc.Authentik.Addresses[idx])
+			}
+		}
+	})
+
+	tests := []struct {
+		name      string
+		address   string
+		addresses []string
+	}{
+		{"with no address", "", nil},
+		{"with both address and addresses", "https://auth1.example.com", []string{"https://auth2.example.com"}},
+		{"with comma separated address", "https://auth1.example.com,https://auth2.example.com", nil},
+		{"with empty address in list", "", []string{"https://auth1.example.com", ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := config.Config{
+				Address:   tt.address,
+				Addresses: tt.addresses,
+			}
+
+			_, err := config.Parse()
+			if err == nil {
+				t.Fatal("expected error, got none")
+			}
+		})
+	}
+}
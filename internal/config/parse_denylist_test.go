@@ -0,0 +1,59 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/config"
+)
+
+func TestParse_Denylist(t *testing.T) {
+	t.Run("with empty value", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if pc.Denylist != nil {
+			t.Errorf("expected denylist to be disabled")
+		}
+	})
+
+	t.Run("with valid value", func(t *testing.T) {
+		config := config.Config{
+			Address:      "https://authentik.example.com",
+			DenylistFile: "/etc/traefik/denylist",
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if pc.Denylist == nil {
+			t.Fatal("expected denylist to be enabled")
+		}
+
+		expectedInterval := 5 * time.Second
+		if pc.Denylist.Interval != expectedInterval {
+			t.Errorf("expected interval %v, got %v", expectedInterval, pc.Denylist.Interval)
+		}
+	})
+
+	t.Run("with invalid interval", func(t *testing.T) {
+		config := config.Config{
+			Address:          "https://authentik.example.com",
+			DenylistFile:     "/etc/traefik/denylist",
+			DenylistInterval: "invalid",
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for invalid denylist interval, got none")
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/config"
+)
+
+func TestParse_Metrics(t *testing.T) {
+	t.Run("with empty value", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if pc.Metrics != nil {
+			t.Errorf("expected metrics to be disabled")
+		}
+	})
+
+	t.Run("with valid values", func(t *testing.T) {
+		config := config.Config{
+			Address:           "https://authentik.example.com",
+			MetricsPath:       "/_authentik/metrics",
+			MetricsAllowedIPs: []string{"10.0.0.0/8", "192.168.1.10", "::1"},
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expectedRanges := []string{"10.0.0.0/8", "192.168.1.10/32", "::1/128"}
+		if len(pc.Metrics.AllowedIPs) != len(expectedRanges) {
+			t.Fatalf("expected %d allowed ranges, got %d", len(expectedRanges), len(pc.Metrics.AllowedIPs))
+		}
+
+		for i, expected := range expectedRanges {
+			if pc.Metrics.AllowedIPs[i].String() != expected {
+				t.Errorf("expected allowed range %s, got %s", expected, pc.Metrics.AllowedIPs[i])
+			}
+		}
+	})
+
+	t.Run("with invalid ip", func(t *testing.T) {
+		config := config.Config{
+			Address:           "https://authentik.example.com",
+			MetricsPath:       "/_authentik/metrics",
+			MetricsAllowedIPs: []string{"invalid"},
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for invalid allowed ip, got none")
+		}
+	})
+
+	t.Run("with no restriction", func(t *testing.T) {
+		config := config.Config{
+			Address:     "https://authentik.example.com",
+			MetricsPath: "/_authentik/metrics",
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for unrestricted metrics, got none")
+		}
+	})
+}
@@ -0,0 +1,241 @@
+package config_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/authentik"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/config"
+)
+
+func TestParse_Rules(t *testing.T) {
+	t.Run("with default status codes", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+			Rules: []config.RuleConfig{
+				{Path: "^/health$", Action: "skip"},
+				{Name: "api", Path: "^/api", Methods: []string{"POST"}, Action: "deny"},
+				{Path: "^/", Hosts: []string{"app.example.com"}, Action: "redirect"},
+				{Path: "^/", Action: "negotiate"},
+			},
+			UnauthorizedPaths: []string{},
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if len(pc.Authentik.Rules) != 4 {
+			t.Fatalf("expected 4 rules, got %d", len(pc.Authentik.Rules))
+		}
+
+		expected := []struct {
+			name       string
+			action     string
+			statusCode int
+		}{
+			{"rules[0]", authentik.ActionSkip, http.StatusOK},
+			{"api", authentik.ActionDeny, http.StatusUnauthorized},
+			{"rules[2]", authentik.ActionRedirect, http.StatusFound},
+			{"rules[3]", authentik.ActionNegotiate, http.StatusUnauthorized},
+		}
+
+		for idx, e := range expected {
+			rule := pc.Authentik.Rules[idx]
+
+			if rule.Name != e.name {
+				t.Errorf("expected rule %d name %s, got %s", idx, e.name, rule.Name)
+			}
+
+			if rule.Action != e.action {
+				t.Errorf("expected rule %d action %s, got %s", idx, e.action, rule.Action)
+			}
+
+			if rule.StatusCode != e.statusCode {
+				t.Errorf("expected rule %d status code %d, got %d", idx, e.statusCode, rule.StatusCode)
+			}
+		}
+	})
+
+	t.Run("with rules before legacy paths", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+			Rules: []config.RuleConfig{
+				{Path: "^/public", Action: "allow"},
+			},
+			SkippedPaths:      []string{"^/public/health$"},
+			UnauthorizedPaths: []string{"^/.*$"},
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expectedActions := []string{authentik.ActionAllow, authentik.ActionSkip, authentik.ActionDeny}
+		if len(pc.Authentik.Rules) != len(expectedActions) {
+			t.Fatalf("expected %d rules, got %d", len(expectedActions), len(pc.Authentik.Rules))
+		}
+
+		for idx, action := range expectedActions {
+			if pc.Authentik.Rules[idx].Action != action {
+				t.Errorf("expected rule %d action %s, got %s", idx, action, pc.Authentik.Rules[idx].Action)
+			}
+		}
+	})
+
+	t.Run("with groups", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+			Rules: []config.RuleConfig{
+				{Path: "^/admin", Action: "redirect", Groups: []string{"admins", "operators"}, GroupsMatch: "all"},
+			},
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		rule := pc.Authentik.Rules[0]
+		if len(rule.Groups) != 2 {
+			t.Errorf("expected 2 groups, got %d", len(rule.Groups))
+		}
+
+		if !rule.MatchAll {
+			t.Errorf("expected rule to match all groups")
+		}
+	})
+
+	tests := []struct {
+		name string
+		rule config.RuleConfig
+	}{
+		{"with missing action", config.RuleConfig{Path: "^/"}},
+		{"with invalid action", config.RuleConfig{Path: "^/", Action: "block"}},
+		{"with invalid path", config.RuleConfig{Path: "^/(", Action: "deny"}},
+		{"with invalid host", config.RuleConfig{Hosts: []string{"~("}, Action: "deny"}},
+		{"with empty host", config.RuleConfig{Hosts: []string{""}, Action: "deny"}},
+		{"with invalid header", config.RuleConfig{Headers: map[string]string{"Accept": "("}, Action: "deny"}},
+		{"with invalid query", config.RuleConfig{Query: map[string]string{"format": "("}, Action: "deny"}},
+		{"with status code on allow", config.RuleConfig{Action: "allow", StatusCode: http.StatusForbidden}},
+		{"with redirect status code on deny", config.RuleConfig{Action: "deny", StatusCode: http.StatusFound}},
+		{"with deny status code on redirect", config.RuleConfig{Action: "redirect", StatusCode: http.StatusForbidden}},
+		{"with status code on negotiate", config.RuleConfig{Action: "negotiate", StatusCode: http.StatusFound}},
+		{"with groups on skip", config.RuleConfig{Action: "skip", Groups: []string{"admins"}}},
+		{"with groups on allow", config.RuleConfig{Action: "allow", Groups: []string{"admins"}}},
+		{"with invalid groups match", config.RuleConfig{Action: "deny", Groups: []string{"admins"}, GroupsMatch: "some"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := config.Config{
+				Address: "https://authentik.example.com",
+				Rules:   []config.RuleConfig{tt.rule},
+			}
+
+			_, err := config.Parse()
+			if err == nil {
+				t.Fatal("expected error, got none")
+			}
+		})
+	}
+}
+
+func TestParse_LegacyRules(t *testing.T) {
+	tests := []struct {
+		name              string
+		skippedPaths      []string
+		unauthorizedPaths []string
+		redirectPaths     []string
+		path              string
+		expectedAction    string
+		expectedRule      string
+	}{
+		{
+			name:              "with no matching paths",
+			unauthorizedPaths: []string{"^/admin"},
+			redirectPaths:     []string{"^/login"},
+			path:              "/test",
+			expectedAction:    authentik.ActionAllow,
+			expectedRule:      "",
+		},
+		{
+			name:              "with matching skipped path",
+			skippedPaths:      []string{"^/test"},
+			unauthorizedPaths: []string{"^/test/longer"},
+			path:              "/test/longer",
+			expectedAction:    authentik.ActionSkip,
+			expectedRule:      "^/test",
+		},
+		{
+			name:              "with matching unauthorized path",
+			unauthorizedPaths: []string{"^/admin", "^/test"},
+			redirectPaths:     []string{"^/login"},
+			path:              "/test",
+			expectedAction:    authentik.ActionDeny,
+			expectedRule:      "^/test",
+		},
+		{
+			name:              "with matching redirect path",
+			unauthorizedPaths: []string{"^/admin"},
+			redirectPaths:     []string{"^/login", "^/test"},
+			path:              "/test",
+			expectedAction:    authentik.ActionRedirect,
+			expectedRule:      "^/test",
+		},
+		{
+			name:              "with longest matching for unauthorized path",
+			unauthorizedPaths: []string{"^/test"},
+			redirectPaths:     []string{"^/.*"},
+			path:              "/test",
+			expectedAction:    authentik.ActionDeny,
+			expectedRule:      "^/test",
+		},
+		{
+			name:              "with longest matching for redirect path",
+			unauthorizedPaths: []string{"^/.*"},
+			redirectPaths:     []string{"^/test"},
+			path:              "/test",
+			expectedAction:    authentik.ActionRedirect,
+			expectedRule:      "^/test",
+		},
+		{
+			name:              "with same length matching for both",
+			unauthorizedPaths: []string{`^/test/?`},
+			redirectPaths:     []string{`^/test/+`},
+			path:              "/test/",
+			expectedAction:    authentik.ActionDeny,
+			expectedRule:      `^/test/?`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := config.Config{
+				Address:           "https://authentik.example.com",
+				SkippedPaths:      tt.skippedPaths,
+				UnauthorizedPaths: tt.unauthorizedPaths,
+				RedirectPaths:     tt.redirectPaths,
+			}
+
+			pc, err := config.Parse()
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com"+tt.path, nil)
+			rule := pc.Authentik.GetRule(req.URL, req)
+
+			if rule.Action != tt.expectedAction {
+				t.Errorf("expected action %s, got %s", tt.expectedAction, rule.Action)
+			}
+
+			if rule.Name != tt.expectedRule {
+				t.Errorf("expected rule %q, got %q", tt.expectedRule, rule.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/config"
+)
+
+func TestParse_Tracing(t *testing.T) {
+	t.Run("with empty value", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if pc.Authentik.Tracing != nil {
+			t.Errorf("expected tracing to be disabled")
+		}
+	})
+
+	t.Run("with default values", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+			Tracing: config.TracingConfig{
+				Endpoint: "http://collector:4318/v1/traces",
+			},
+		}
+
+		pc, err := config.Parse()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expectedServiceName := "traefik-authentik-forward-plugin"
+		if pc.Authentik.Tracing.ServiceName != expectedServiceName {
+			t.Errorf("expected service name %s, got %s", expectedServiceName, pc.Authentik.Tracing.ServiceName)
+		}
+
+		expectedInterval := 5 * time.Second
+		if pc.Authentik.Tracing.Interval != expectedInterval {
+			t.Errorf("expected interval %v, got %v", expectedInterval, pc.Authentik.Tracing.Interval)
+		}
+
+		expectedTimeout := 10 * time.Second
+		if pc.Authentik.Tracing.Timeout != expectedTimeout {
+			t.Errorf("expected timeout %v, got %v", expectedTimeout, pc.Authentik.Tracing.Timeout)
+		}
+	})
+
+	t.Run("with invalid endpoint", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+			Tracing: config.TracingConfig{
+				Endpoint: "collector:4318",
+			},
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for invalid endpoint, got none")
+		}
+	})
+
+	t.Run("with invalid root sample rate", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+			Tracing: config.TracingConfig{
+				Endpoint:       "http://collector:4318/v1/traces",
+				RootSampleRate: 1.5,
+			},
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for invalid root sample rate, got none")
+		}
+	})
+
+	t.Run("with invalid interval", func(t *testing.T) {
+		config := config.Config{
+			Address: "https://authentik.example.com",
+			Tracing: config.TracingConfig{
+				Endpoint: "http://collector:4318/v1/traces",
+				Interval: "0s",
+			},
+		}
+
+		_, err := config.Parse()
+		if err == nil {
+			t.Fatal("expected error for invalid interval, got none")
+		}
+	})
+}
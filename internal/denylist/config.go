@@ -0,0 +1,10 @@
+package denylist
+
+import (
+	"time"
+)
+
+type Config struct {
+	File     string
+	Interval time.Duration
+}
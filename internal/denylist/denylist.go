@@ -0,0 +1,160 @@
+package denylist
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/session"
+)
+
+const (
+	uidPrefix      = "uid:"
+	usernamePrefix = "username:"
+	groupPrefix    = "group:"
+)
+
+type Denylist struct {
+	config *Config
+
+	mu      sync.RWMutex
+	entries map[string]struct{}
+	modTime time.Time
+	size    int64
+}
+
+func New(context context.Context, config *Config) (*Denylist, error) {
+	d := &Denylist{
+		config:  config,
+		entries: make(map[string]struct{}),
+	}
+
+	if err := d.reload(); err != nil {
+		return nil, err
+	}
+
+	if config.Interval > 0 {
+		// watch the file for changes until the plugin is discarded
+		go d.watch(context)
+	}
+
+	return d, nil
+}
+
+// Match returns whether the user of an authenticated session is denied, by
+// uid, username or any of its groups.
+func (d *Denylist) Match(s *session.Session) bool {
+	if s == nil || !s.IsAuthenticated {
+		return false
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	// user identifiers share the format of the denylist entries
+	for _, user := range s.GetUsers() {
+		if _, ok := d.entries[user]; ok {
+			return true
+		}
+	}
+
+	for _, group := range s.GetGroups() {
+		if _, ok := d.entries[groupPrefix+group]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *Denylist) Len() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return len(d.entries)
+}
+
+func (d *Denylist) watch(context context.Context) {
+	ticker := time.NewTicker(d.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// keep the last loaded entries if the file is invalid
+			_ = d.reload()
+		case <-context.Done():
+			return
+		}
+	}
+}
+
+func (d *Denylist) reload() error {
+	info, err := os.Stat(d.config.File)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDenylistLoad, err)
+	}
+
+	d.mu.RLock()
+	unchanged := info.ModTime().Equal(d.modTime) && info.Size() == d.size
+	d.mu.RUnlock()
+
+	if unchanged {
+		return nil
+	}
+
+	data, err := os.ReadFile(d.config.File)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDenylistLoad, err)
+	}
+
+	entries, err := parse(data)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDenylistLoad, err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.entries = entries
+	d.modTime = info.ModTime()
+	d.size = info.Size()
+
+	return nil
+}
+
+func parse(data []byte) (map[string]struct{}, error) {
+	entries := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			// skip empty lines and comments
+			continue
+		}
+
+		prefix, value, ok := strings.Cut(line, ":")
+		if value = strings.TrimSpace(value); !ok || value == "" {
+			return nil, fmt.Errorf("line %d is not valid: %s", n, line)
+		}
+
+		switch prefix + ":" {
+		case uidPrefix:
+			entries[session.UserByUID(value)] = struct{}{}
+		case usernamePrefix:
+			entries[session.UserByUsername(value)] = struct{}{}
+		case groupPrefix:
+			entries[groupPrefix+value] = struct{}{}
+		default:
+			return nil, fmt.Errorf("line %d has an unknown type: %s", n, prefix)
+		}
+	}
+
+	return entries, scanner.Err()
+}
@@ -0,0 +1,119 @@
+package denylist_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/denylist"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/session"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+
+	// replace the file atomically, so it is never read half written
+	if err := os.WriteFile(path+".tmp", []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write denylist: %v", err)
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		t.Fatalf("failed to write denylist: %v", err)
+	}
+}
+
+func newSession(uid string, username string, groups string) *session.Session {
+	return &session.Session{
+		IsAuthenticated: true,
+		Headers: http.Header{
+			"X-Authentik-Uid":      []string{uid},
+			"X-Authentik-Username": []string{username},
+			"X-Authentik-Groups":   []string{groups},
+		},
+	}
+}
+
+func TestDenylist(t *testing.T) {
+	t.Run("match entries", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "denylist")
+		writeFile(t, path, "# incident 42\nuid: 5f8e1b6c\nusername:jdoe\n\ngroup:contractors\n")
+
+		dl, err := denylist.New(context.Background(), &denylist.Config{File: path})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		tests := []struct {
+			name     string
+			session  *session.Session
+			expected bool
+		}{
+			{"by uid", newSession("5f8e1b6c", "other", ""), true},
+			{"by username", newSession("other", "jdoe", ""), true},
+			{"by group", newSession("other", "other", "admins|contractors"), true},
+			{"with other user", newSession("other", "other", "admins"), false},
+			{"with unauthenticated session", &session.Session{IsAuthenticated: false}, false},
+		}
+
+		for _, tt := range tests {
+			if dl.Match(tt.session) != tt.expected {
+				t.Errorf("%s: expected match to be %t", tt.name, tt.expected)
+			}
+		}
+	})
+
+	t.Run("with missing file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "denylist")
+
+		_, err := denylist.New(context.Background(), &denylist.Config{File: path})
+		if !errors.Is(err, denylist.ErrDenylistLoad) {
+			t.Errorf("expected load error, got %v", err)
+		}
+	})
+
+	t.Run("with invalid entry", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "denylist")
+		writeFile(t, path, "email:jdoe@example.com\n")
+
+		_, err := denylist.New(context.Background(), &denylist.Config{File: path})
+		if !errors.Is(err, denylist.ErrDenylistLoad) {
+			t.Errorf("expected load error, got %v", err)
+		}
+	})
+
+	t.Run("reload on change", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "denylist")
+		writeFile(t, path, "")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		dl, err := denylist.New(ctx, &denylist.Config{File: path, Interval: 10 * time.Millisecond})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		writeFile(t, path, "username:jdoe\n")
+
+		deadline := time.Now().Add(time.Second)
+		for !dl.Match(newSession("", "jdoe", "")) {
+			if time.Now().After(deadline) {
+				t.Fatal("expected denylist to be reloaded")
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		// check that an invalid file keeps the last entries
+		writeFile(t, path, "invalid\n")
+		time.Sleep(50 * time.Millisecond)
+
+		if dl.Len() != 1 {
+			t.Errorf("expected 1 entry, got %d", dl.Len())
+		}
+	})
+}
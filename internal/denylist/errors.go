@@ -0,0 +1,7 @@
+package denylist
+
+import (
+	"errors"
+)
+
+var ErrDenylistLoad = errors.New("failed to load denylist")
@@ -0,0 +1,13 @@
+package httputil
+
+import (
+	"net/http"
+)
+
+// IsCORSPreflight reports whether the request is a CORS preflight request, as
+// sent by browsers before cross origin requests.
+func IsCORSPreflight(req *http.Request) bool {
+	return req.Method == http.MethodOptions &&
+		req.Header.Get("Origin") != "" &&
+		req.Header.Get("Access-Control-Request-Method") != ""
+}
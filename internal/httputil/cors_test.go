@@ -0,0 +1,57 @@
+package httputil_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/httputil"
+)
+
+func TestIsCORSPreflight(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		headers  map[string]string
+		expected bool
+	}{
+		{
+			name:     "with preflight request",
+			method:   http.MethodOptions,
+			headers:  map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "POST"},
+			expected: true,
+		},
+		{
+			name:     "with options request without origin",
+			method:   http.MethodOptions,
+			headers:  map[string]string{"Access-Control-Request-Method": "POST"},
+			expected: false,
+		},
+		{
+			name:     "with options request without request method",
+			method:   http.MethodOptions,
+			headers:  map[string]string{"Origin": "https://app.example.com"},
+			expected: false,
+		},
+		{
+			name:     "with cross origin get request",
+			method:   http.MethodGet,
+			headers:  map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "POST"},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://example.com/api", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			isPreflight := httputil.IsCORSPreflight(req)
+			if isPreflight != tt.expected {
+				t.Errorf("expected isPreflight to be %t, got %t", tt.expected, isPreflight)
+			}
+		})
+	}
+}
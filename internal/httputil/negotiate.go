@@ -0,0 +1,38 @@
+package httputil
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// NegotiationHeaders are the request headers used to tell browser navigations
+// apart from scripted requests.
+//
+//nolint:gochecknoglobals
+var NegotiationHeaders = []string{"Accept", "Sec-Fetch-Mode", "X-Requested-With"}
+
+// IsNavigation reports whether the request is a browser navigation, as opposed
+// to a request sent by a script such as fetch or XMLHttpRequest.
+func IsNavigation(req *http.Request) bool {
+	// fetch metadata headers are sent by every modern browser
+	if mode := req.Header.Get("Sec-Fetch-Mode"); mode != "" {
+		return mode == "navigate"
+	}
+
+	if strings.EqualFold(req.Header.Get("X-Requested-With"), "XMLHttpRequest") {
+		return false
+	}
+
+	// fall back to the accepted media types
+	for _, accept := range req.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(mediaRange)
+			if err == nil && (mediaType == "text/html" || mediaType == "application/xhtml+xml") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
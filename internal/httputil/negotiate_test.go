@@ -0,0 +1,62 @@
+package httputil_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/httputil"
+)
+
+func TestIsNavigation(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected bool
+	}{
+		{
+			name:     "with navigate fetch mode",
+			headers:  map[string]string{"Sec-Fetch-Mode": "navigate", "Accept": "*/*"},
+			expected: true,
+		},
+		{
+			name:     "with cors fetch mode",
+			headers:  map[string]string{"Sec-Fetch-Mode": "cors", "Accept": "text/html"},
+			expected: false,
+		},
+		{
+			name:     "with html accept",
+			headers:  map[string]string{"Accept": "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8"},
+			expected: true,
+		},
+		{
+			name:     "with json accept",
+			headers:  map[string]string{"Accept": "application/json"},
+			expected: false,
+		},
+		{
+			name:     "with xml http request",
+			headers:  map[string]string{"X-Requested-With": "XMLHttpRequest", "Accept": "text/html"},
+			expected: false,
+		},
+		{
+			name:     "with no headers",
+			headers:  map[string]string{},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			isNavigation := httputil.IsNavigation(req)
+			if isNavigation != tt.expected {
+				t.Errorf("expected isNavigation to be %t, got %t", tt.expected, isNavigation)
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+package metrics
+
+import (
+	"net"
+)
+
+type Config struct {
+	Path       string
+	AllowedIPs []*net.IPNet
+	Token      string
+}
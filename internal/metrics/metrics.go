@@ -0,0 +1,231 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//nolint:gochecknoglobals
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Collector writes its samples in prometheus text format.
+type Collector interface {
+	Write(w io.Writer) error
+}
+
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) Register(collectors ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.collectors = append(r.collectors, collectors...)
+}
+
+// Write writes every registered collector in prometheus text format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	for _, c := range collectors {
+		if err := c.Write(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	return &CounterVec{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]float64),
+	}
+}
+
+// Inc increments the counter with the given label values by one.
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add increments the counter with the given label values by v.
+func (c *CounterVec) Add(v float64, values ...string) {
+	key := formatLabels(c.labels, values)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[key] += v
+}
+
+// Get returns the value of the counter with the given label values.
+func (c *CounterVec) Get(values ...string) float64 {
+	key := formatLabels(c.labels, values)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.values[key]
+}
+
+func (c *CounterVec) Write(w io.Writer) error {
+	c.mu.Lock()
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var b strings.Builder
+	writeHeader(&b, c.name, c.help, "counter")
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s%s %s\n", c.name, k, formatValue(c.values[k]))
+	}
+	c.mu.Unlock()
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type Histogram struct {
+	name    string
+	help    string
+	buckets []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func NewHistogram(name string, help string, buckets []float64) *Histogram {
+	return &Histogram{
+		name:    name,
+		help:    help,
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, bound := range h.buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+
+	h.sum += v
+	h.count++
+}
+
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.count
+}
+
+func (h *Histogram) Write(w io.Writer) error {
+	h.mu.Lock()
+
+	var b strings.Builder
+	writeHeader(&b, h.name, h.help, "histogram")
+	for i, bound := range h.buckets {
+		fmt.Fprintf(&b, "%s_bucket{le=\"%s\"} %d\n", h.name, formatValue(bound), h.counts[i])
+	}
+
+	fmt.Fprintf(&b, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(&b, "%s_sum %s\n", h.name, formatValue(h.sum))
+	fmt.Fprintf(&b, "%s_count %d\n", h.name, h.count)
+	h.mu.Unlock()
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ValueFunc is a gauge or counter whose value is read when collected.
+type ValueFunc struct {
+	name  string
+	help  string
+	kind  string
+	value func() float64
+}
+
+func NewGaugeFunc(name string, help string, value func() float64) *ValueFunc {
+	return &ValueFunc{name: name, help: help, kind: "gauge", value: value}
+}
+
+func NewCounterFunc(name string, help string, value func() float64) *ValueFunc {
+	return &ValueFunc{name: name, help: help, kind: "counter", value: value}
+}
+
+func (f *ValueFunc) Write(w io.Writer) error {
+	var b strings.Builder
+	writeHeader(&b, f.name, f.help, f.kind)
+	fmt.Fprintf(&b, "%s %s\n", f.name, formatValue(f.value()))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeHeader(b *strings.Builder, name string, help string, kind string) {
+	fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, kind)
+}
+
+func formatLabels(labels []string, values []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(labels))
+	for i, l := range labels {
+		v := ""
+		if i < len(values) {
+			v = values[i]
+		}
+
+		pairs[i] = l + "=\"" + labelEscaper.Replace(v) + "\""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
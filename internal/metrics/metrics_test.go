@@ -0,0 +1,85 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/metrics"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Run("write counters", func(t *testing.T) {
+		counter := metrics.NewCounterVec("test_total", "Test counter.", "rule", "decision")
+		counter.Inc("^/api/.*$", "allowed")
+		counter.Inc("^/api/.*$", "allowed")
+		counter.Inc(`quote " and \ backslash`, "denied")
+
+		registry := metrics.NewRegistry()
+		registry.Register(counter)
+
+		var b strings.Builder
+		if err := registry.Write(&b); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := "# HELP test_total Test counter.\n" +
+			"# TYPE test_total counter\n" +
+			"test_total{rule=\"^/api/.*$\",decision=\"allowed\"} 2\n" +
+			"test_total{rule=\"quote \\\" and \\\\ backslash\",decision=\"denied\"} 1\n"
+
+		if b.String() != expected {
+			t.Errorf("expected output:\n%s\ngot:\n%s", expected, b.String())
+		}
+	})
+
+	t.Run("write histograms", func(t *testing.T) {
+		histogram := metrics.NewHistogram("test_seconds", "Test histogram.", []float64{0.1, 1})
+		histogram.Observe(0.05)
+		histogram.Observe(0.5)
+		histogram.Observe(5)
+
+		registry := metrics.NewRegistry()
+		registry.Register(histogram)
+
+		var b strings.Builder
+		if err := registry.Write(&b); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := "# HELP test_seconds Test histogram.\n" +
+			"# TYPE test_seconds histogram\n" +
+			"test_seconds_bucket{le=\"0.1\"} 1\n" +
+			"test_seconds_bucket{le=\"1\"} 2\n" +
+			"test_seconds_bucket{le=\"+Inf\"} 3\n" +
+			"test_seconds_sum 5.55\n" +
+			"test_seconds_count 3\n"
+
+		if b.String() != expected {
+			t.Errorf("expected output:\n%s\ngot:\n%s", expected, b.String())
+		}
+	})
+
+	t.Run("write value functions", func(t *testing.T) {
+		registry := metrics.NewRegistry()
+		registry.Register(
+			metrics.NewGaugeFunc("test_gauge", "Test gauge.", func() float64 { return 2 }),
+			metrics.NewCounterFunc("test_count_total", "Test counter.", func() float64 { return 3 }),
+		)
+
+		var b strings.Builder
+		if err := registry.Write(&b); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		expected := "# HELP test_gauge Test gauge.\n" +
+			"# TYPE test_gauge gauge\n" +
+			"test_gauge 2\n" +
+			"# HELP test_count_total Test counter.\n" +
+			"# TYPE test_count_total counter\n" +
+			"test_count_total 3\n"
+
+		if b.String() != expected {
+			t.Errorf("expected output:\n%s\ngot:\n%s", expected, b.String())
+		}
+	})
+}
@@ -0,0 +1,196 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultPoolSize = 8
+
+type Client struct {
+	config *Config
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+func New(context context.Context, config *Config) *Client {
+	c := &Client{
+		config: config,
+	}
+
+	// close idle connections when the plugin is discarded
+	go func() {
+		<-context.Done()
+		c.Close()
+	}()
+
+	return c
+}
+
+// Do sends a command and returns its reply, see readReply for reply types.
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := c.getDeadline(ctx); ok {
+		_ = cn.SetDeadline(deadline)
+	} else {
+		_ = cn.SetDeadline(time.Time{})
+	}
+
+	reply, err := cn.do(args)
+	if err != nil {
+		// connection state is unknown after a failure
+		_ = cn.Close()
+		return nil, err
+	}
+
+	c.put(cn)
+
+	if redisErr, ok := reply.(*Error); ok {
+		return nil, redisErr
+	}
+
+	return reply, nil
+}
+
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	for _, cn := range c.idle {
+		_ = cn.Close()
+	}
+
+	c.idle = nil
+}
+
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+
+		return cn, nil
+	}
+	c.mu.Unlock()
+
+	return c.dial(ctx)
+}
+
+func (c *Client) put(cn *conn) {
+	poolSize := c.config.PoolSize
+	if poolSize <= 0 {
+		poolSize = defaultPoolSize
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || len(c.idle) >= poolSize {
+		_ = cn.Close()
+		return
+	}
+
+	c.idle = append(c.idle, cn)
+}
+
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	dialer := &net.Dialer{Timeout: c.config.Timeout}
+
+	nc, err := dialer.DialContext(ctx, "tcp", c.config.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	cn := &conn{
+		Conn:   nc,
+		reader: bufio.NewReader(nc),
+		writer: bufio.NewWriter(nc),
+	}
+
+	if deadline, ok := c.getDeadline(ctx); ok {
+		_ = cn.SetDeadline(deadline)
+	}
+
+	if err := c.handshake(cn); err != nil {
+		_ = cn.Close()
+		return nil, err
+	}
+
+	return cn, nil
+}
+
+func (c *Client) handshake(cn *conn) error {
+	// authenticate the connection if a password is configured
+	if c.config.Password != "" {
+		if err := cn.expectOK("AUTH", c.config.Password); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	// select the configured database
+	if c.config.Database != 0 {
+		if err := cn.expectOK("SELECT", strconv.Itoa(c.config.Database)); err != nil {
+			return fmt.Errorf("failed to select database: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) getDeadline(ctx context.Context) (time.Time, bool) {
+	deadline, ok := ctx.Deadline()
+
+	if c.config.Timeout > 0 {
+		timeout := time.Now().Add(c.config.Timeout)
+		if !ok || timeout.Before(deadline) {
+			return timeout, true
+		}
+	}
+
+	return deadline, ok
+}
+
+func (cn *conn) do(args []string) (any, error) {
+	if err := writeCommand(cn.writer, args); err != nil {
+		return nil, err
+	}
+
+	return readReply(cn.reader)
+}
+
+func (cn *conn) expectOK(args ...string) error {
+	reply, err := cn.do(args)
+	if err != nil {
+		return err
+	}
+
+	if redisErr, ok := reply.(*Error); ok {
+		return redisErr
+	}
+
+	return nil
+}
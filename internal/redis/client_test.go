@@ -0,0 +1,133 @@
+package redis_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/redis"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/redis/redistest"
+)
+
+func TestClient(t *testing.T) {
+	t.Run("get set and delete", func(t *testing.T) {
+		server := redistest.NewServer()
+		defer server.Close()
+
+		client := redis.New(context.Background(), &redis.Config{Address: server.Address})
+		defer client.Close()
+
+		if _, ok, err := client.Get(context.Background(), "key"); err != nil || ok {
+			t.Fatalf("expected missing key, got ok=%v err=%v", ok, err)
+		}
+
+		if err := client.Set(context.Background(), "key", "value\r\nwith crlf", time.Minute); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		value, ok, err := client.Get(context.Background(), "key")
+		if err != nil || !ok {
+			t.Fatalf("expected key, got ok=%v err=%v", ok, err)
+		}
+
+		if value != "value\r\nwith crlf" {
+			t.Errorf("expected value to be preserved, got %q", value)
+		}
+
+		if err := client.Del(context.Background(), "key"); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if _, ok, _ := client.Get(context.Background(), "key"); ok {
+			t.Error("expected key to be deleted")
+		}
+	})
+
+	t.Run("with ttl", func(t *testing.T) {
+		server := redistest.NewServer()
+		defer server.Close()
+
+		client := redis.New(context.Background(), &redis.Config{Address: server.Address})
+		defer client.Close()
+
+		_ = client.Set(context.Background(), "key", "value", 20*time.Millisecond)
+
+		time.Sleep(40 * time.Millisecond)
+
+		if _, ok, _ := client.Get(context.Background(), "key"); ok {
+			t.Error("expected key to be expired")
+		}
+	})
+
+	t.Run("with sub millisecond ttl", func(t *testing.T) {
+		server := redistest.NewServer()
+		defer server.Close()
+
+		client := redis.New(context.Background(), &redis.Config{Address: server.Address})
+		defer client.Close()
+
+		// the ttl is rounded up instead of being sent as zero
+		if err := client.Set(context.Background(), "key", "value", 500*time.Microsecond); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if err := client.PExpire(context.Background(), "key", 500*time.Microsecond); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("with password", func(t *testing.T) {
+		server := redistest.NewUnstartedServer()
+		server.Password = "secret"
+		server.Start()
+		defer server.Close()
+
+		client := redis.New(context.Background(), &redis.Config{Address: server.Address, Password: "wrong"})
+		defer client.Close()
+
+		_, err := client.Do(context.Background(), "PING")
+
+		var redisErr *redis.Error
+		if !errors.As(err, &redisErr) {
+			t.Fatalf("expected redis error, got %v", err)
+		}
+
+		client = redis.New(context.Background(), &redis.Config{Address: server.Address, Password: "secret"})
+		defer client.Close()
+
+		reply, err := client.Do(context.Background(), "PING")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		if reply != "PONG" {
+			t.Errorf("expected PONG, got %v", reply)
+		}
+	})
+
+	t.Run("after close", func(t *testing.T) {
+		server := redistest.NewServer()
+		defer server.Close()
+
+		client := redis.New(context.Background(), &redis.Config{Address: server.Address})
+		client.Close()
+
+		_, err := client.Do(context.Background(), "PING")
+		if !errors.Is(err, redis.ErrClosed) {
+			t.Errorf("expected closed error, got %v", err)
+		}
+	})
+
+	t.Run("with server unavailable", func(t *testing.T) {
+		server := redistest.NewServer()
+		server.Close()
+
+		client := redis.New(context.Background(), &redis.Config{Address: server.Address, Timeout: time.Second})
+		defer client.Close()
+
+		if _, err := client.Do(context.Background(), "PING"); err == nil {
+			t.Error("expected error, got none")
+		}
+	})
+}
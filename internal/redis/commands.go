@@ -0,0 +1,103 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+func (c *Client) Get(ctx context.Context, key string) (string, bool, error) {
+	reply, err := c.Do(ctx, "GET", key)
+	if err != nil {
+		return "", false, err
+	}
+
+	if reply == nil {
+		// key does not exist
+		return "", false, nil
+	}
+
+	value, ok := reply.(string)
+	if !ok {
+		return "", false, fmt.Errorf("%w: unexpected GET reply %T", ErrProtocol, reply)
+	}
+
+	return value, true, nil
+}
+
+func (c *Client) Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	_, err := c.Do(ctx, "SET", key, value, "PX", formatMilliseconds(ttl))
+	return err
+}
+
+func (c *Client) Del(ctx context.Context, keys ...string) error {
+	_, err := c.Do(ctx, append([]string{"DEL"}, keys...)...)
+	return err
+}
+
+func (c *Client) PExpire(ctx context.Context, key string, ttl time.Duration) error {
+	_, err := c.Do(ctx, "PEXPIRE", key, formatMilliseconds(ttl))
+	return err
+}
+
+// PTTL returns the remaining time to live of key, or a negative duration if
+// the key does not exist or never expires.
+func (c *Client) PTTL(ctx context.Context, key string) (time.Duration, error) {
+	reply, err := c.Do(ctx, "PTTL", key)
+	if err != nil {
+		return 0, err
+	}
+
+	ms, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("%w: unexpected PTTL reply %T", ErrProtocol, reply)
+	}
+
+	if ms < 0 {
+		return time.Duration(ms), nil
+	}
+
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+func (c *Client) SAdd(ctx context.Context, key string, members ...string) error {
+	_, err := c.Do(ctx, append([]string{"SADD", key}, members...)...)
+	return err
+}
+
+func (c *Client) SRem(ctx context.Context, key string, members ...string) error {
+	_, err := c.Do(ctx, append([]string{"SREM", key}, members...)...)
+	return err
+}
+
+func (c *Client) SMembers(ctx context.Context, key string) ([]string, error) {
+	reply, err := c.Do(ctx, "SMEMBERS", key)
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := reply.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: unexpected SMEMBERS reply %T", ErrProtocol, reply)
+	}
+
+	members := make([]string, 0, len(values))
+	for _, v := range values {
+		member, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: unexpected SMEMBERS member %T", ErrProtocol, v)
+		}
+
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+// formatMilliseconds rounds ttl up to whole milliseconds, as redis rejects a
+// zero expire time that truncating a sub millisecond ttl would send.
+func formatMilliseconds(ttl time.Duration) string {
+	ms := (ttl + time.Millisecond - 1).Milliseconds()
+	return strconv.FormatInt(max(ms, 1), 10)
+}
@@ -0,0 +1,13 @@
+package redis
+
+import (
+	"time"
+)
+
+type Config struct {
+	Address  string
+	Password string
+	Database int
+	Timeout  time.Duration
+	PoolSize int
+}
@@ -0,0 +1,18 @@
+package redis
+
+import (
+	"errors"
+)
+
+var (
+	ErrProtocol = errors.New("invalid redis protocol response")
+	ErrClosed   = errors.New("redis client is closed")
+)
+
+type Error struct {
+	Message string
+}
+
+func (e *Error) Error() string {
+	return "redis: " + e.Message
+}
@@ -0,0 +1,375 @@
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is an in-process server speaking a subset of the Redis protocol,
+// meant to be used in tests.
+type Server struct {
+	Address  string
+	Password string
+
+	listener net.Listener
+
+	mu      sync.Mutex
+	values  map[string]string
+	sets    map[string]map[string]struct{}
+	expires map[string]time.Time
+	conns   map[net.Conn]struct{}
+}
+
+// NewServer starts and returns a new Server.
+func NewServer() *Server {
+	s := NewUnstartedServer()
+	s.Start()
+
+	return s
+}
+
+// NewUnstartedServer returns a new Server that is not started yet, so its
+// settings can be changed before calling Start.
+func NewUnstartedServer() *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("redistest: failed to listen: %v", err))
+	}
+
+	s := &Server{
+		Address:  listener.Addr().String(),
+		listener: listener,
+		values:   make(map[string]string),
+		sets:     make(map[string]map[string]struct{}),
+		expires:  make(map[string]time.Time),
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	return s
+}
+
+func (s *Server) Start() {
+	go s.serve()
+}
+
+func (s *Server) Close() {
+	_ = s.listener.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for c := range s.conns {
+		_ = c.Close()
+	}
+}
+
+// Keys returns the keys currently stored in the server.
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]string, 0, len(s.values)+len(s.sets))
+	for k := range s.values {
+		if s.isAlive(k) {
+			keys = append(keys, k)
+		}
+	}
+
+	for k := range s.sets {
+		if s.isAlive(k) {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys
+}
+
+// Value returns the raw value stored for a key.
+func (s *Server) Value(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isAlive(key) {
+		return "", false
+	}
+
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// TTL returns the remaining time to live of a key.
+func (s *Server) TTL(key string) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isAlive(key) {
+		return 0, false
+	}
+
+	exp, ok := s.expires[key]
+	if !ok {
+		return 0, false
+	}
+
+	return time.Until(exp), true
+}
+
+func (s *Server) serve() {
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+
+		_ = c.Close()
+	}()
+
+	r := bufio.NewReader(c)
+	authenticated := s.Password == ""
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		cmd := strings.ToUpper(args[0])
+		if !authenticated && cmd != "AUTH" {
+			_, _ = io.WriteString(c, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+
+		switch cmd {
+		case "AUTH":
+			if len(args) != 2 || args[1] != s.Password {
+				_, _ = io.WriteString(c, "-WRONGPASS invalid password\r\n")
+				continue
+			}
+
+			authenticated = true
+			_, _ = io.WriteString(c, "+OK\r\n")
+		default:
+			_, _ = io.WriteString(c, s.exec(cmd, args[1:]))
+		}
+	}
+}
+
+func (s *Server) exec(cmd string, args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch cmd {
+	case "PING":
+		return "+PONG\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		if len(args) != 1 || !s.isAlive(args[0]) {
+			return "$-1\r\n"
+		}
+
+		v := s.values[args[0]]
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	case "SET":
+		if len(args) < 2 {
+			return "-ERR wrong number of arguments\r\n"
+		}
+
+		s.delete(args[0])
+		s.values[args[0]] = args[1]
+
+		if len(args) == 4 && strings.ToUpper(args[2]) == "PX" {
+			ms, err := strconv.Atoi(args[3])
+			if err != nil || ms <= 0 {
+				return "-ERR invalid expire time\r\n"
+			}
+
+			s.expires[args[0]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+
+		return "+OK\r\n"
+	case "DEL":
+		deleted := 0
+		for _, k := range args {
+			if s.isAlive(k) {
+				deleted++
+			}
+
+			s.delete(k)
+		}
+
+		return fmt.Sprintf(":%d\r\n", deleted)
+	case "PEXPIRE":
+		if len(args) != 2 {
+			return "-ERR wrong number of arguments\r\n"
+		}
+
+		ms, err := strconv.Atoi(args[1])
+		if err != nil {
+			return "-ERR invalid expire time\r\n"
+		}
+
+		if !s.isAlive(args[0]) {
+			return ":0\r\n"
+		}
+
+		s.expires[args[0]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+
+		return ":1\r\n"
+	case "PTTL":
+		if len(args) != 1 {
+			return "-ERR wrong number of arguments\r\n"
+		}
+
+		if !s.isAlive(args[0]) {
+			return ":-2\r\n"
+		}
+
+		exp, ok := s.expires[args[0]]
+		if !ok {
+			return ":-1\r\n"
+		}
+
+		return fmt.Sprintf(":%d\r\n", time.Until(exp).Milliseconds())
+	case "SADD":
+		if len(args) < 2 {
+			return "-ERR wrong number of arguments\r\n"
+		}
+
+		if !s.isAlive(args[0]) {
+			s.sets[args[0]] = make(map[string]struct{})
+		}
+
+		set, ok := s.sets[args[0]]
+		if !ok {
+			return "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+		}
+
+		added := 0
+		for _, m := range args[1:] {
+			if _, ok := set[m]; !ok {
+				set[m] = struct{}{}
+				added++
+			}
+		}
+
+		return fmt.Sprintf(":%d\r\n", added)
+	case "SREM":
+		if len(args) < 2 {
+			return "-ERR wrong number of arguments\r\n"
+		}
+
+		if !s.isAlive(args[0]) {
+			return ":0\r\n"
+		}
+
+		removed := 0
+		for _, m := range args[1:] {
+			if _, ok := s.sets[args[0]][m]; ok {
+				delete(s.sets[args[0]], m)
+				removed++
+			}
+		}
+
+		if len(s.sets[args[0]]) == 0 {
+			s.delete(args[0])
+		}
+
+		return fmt.Sprintf(":%d\r\n", removed)
+	case "SMEMBERS":
+		if len(args) != 1 || !s.isAlive(args[0]) {
+			return "*0\r\n"
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "*%d\r\n", len(s.sets[args[0]]))
+		for m := range s.sets[args[0]] {
+			fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(m), m)
+		}
+
+		return b.String()
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", cmd)
+	}
+}
+
+func (s *Server) isAlive(key string) bool {
+	_, isValue := s.values[key]
+	_, isSet := s.sets[key]
+
+	if !isValue && !isSet {
+		return false
+	}
+
+	if exp, ok := s.expires[key]; ok && time.Now().After(exp) {
+		s.delete(key)
+		return false
+	}
+
+	return true
+}
+
+func (s *Server) delete(key string) {
+	delete(s.values, key)
+	delete(s.sets, key)
+	delete(s.expires, key)
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected command %q", line)
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("unexpected command size %q", line)
+	}
+
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.Atoi(strings.TrimRight(header, "\r\n")[1:])
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+
+		args = append(args, string(buf[:size]))
+	}
+
+	return args, nil
+}
@@ -0,0 +1,111 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func writeCommand(w *bufio.Writer, args []string) error {
+	// commands are sent as arrays of bulk strings
+	if _, err := fmt.Fprintf(w, "*%d\r\n", len(args)); err != nil {
+		return err
+	}
+
+	for _, arg := range args {
+		if _, err := fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+// readReply reads a reply and returns it as a string, an int64, a slice of
+// replies, an *Error or nil for null replies.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if line == "" {
+		return nil, ErrProtocol
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return &Error{Message: line[1:]}, nil
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrProtocol, err)
+		}
+
+		return n, nil
+	case '$':
+		return readBulk(r, line[1:])
+	case '*':
+		return readArray(r, line[1:])
+	default:
+		return nil, fmt.Errorf("%w: unexpected reply type %q", ErrProtocol, line[0])
+	}
+}
+
+func readBulk(r *bufio.Reader, header string) (any, error) {
+	size, err := strconv.Atoi(header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrProtocol, err)
+	}
+
+	if size < 0 {
+		return nil, nil //nolint:nilnil
+	}
+
+	buf := make([]byte, size+2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	return string(buf[:size]), nil
+}
+
+func readArray(r *bufio.Reader, header string) (any, error) {
+	size, err := strconv.Atoi(header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrProtocol, err)
+	}
+
+	if size < 0 {
+		return nil, nil //nolint:nilnil
+	}
+
+	values := make([]any, 0, size)
+	for i := 0; i < size; i++ {
+		v, err := readReply(r)
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, v)
+	}
+
+	return values, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasSuffix(line, "\r\n") {
+		return "", ErrProtocol
+	}
+
+	return line[:len(line)-2], nil
+}
@@ -2,20 +2,33 @@ package session
 
 import (
 	"context"
-	"net/http"
 	"time"
 )
 
 type Client interface {
-	Get(cookies []*http.Cookie) *Session
-	Set(cookies []*http.Cookie, meta *Session)
-	Delete(cookies []*http.Cookie)
+	Get(key string) (*Session, time.Time)
+	GetStale(key string) *Session
+	Set(key string, meta *Session, ttl time.Duration)
+	Delete(key string)
+	DeleteUser(user string, host string)
+	Stats() Stats
 }
 
-func NewClient(context context.Context, duration time.Duration) Client { //nolint:ireturn
-	if duration == 0 {
+// Stats are the usage statistics of a session client.
+type Stats struct {
+	Entries   int
+	Bytes     int64
+	Evictions uint64
+}
+
+func NewClient(context context.Context, config *Config) Client { //nolint:ireturn
+	if config.Duration == 0 {
 		return NewStandardClient()
 	}
 
-	return NewCacheClient(context, duration)
+	if config.Backend == BackendRedis {
+		return NewRedisClient(context, config)
+	}
+
+	return NewCacheClient(context, config)
 }
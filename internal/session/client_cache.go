@@ -1,63 +1,303 @@
 package session
 
 import (
+	"container/list"
 	"context"
-	"net/http"
+	"crypto/cipher"
 	"sync"
 	"time"
 )
 
 type CacheClient struct {
-	context  context.Context //nolint:containedctx
-	duration time.Duration
-	store    sync.Map
+	context context.Context //nolint:containedctx
+	config  *Config
+	aead    cipher.AEAD
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	users   map[string]map[string]struct{}
+	lru     *list.List
+	size    int64
+
+	evictions uint64
+}
+
+type cacheEntry struct {
+	key     string
+	users   []string
+	session *Session
+	size    int64
+	expires time.Time
 }
 
-func NewCacheClient(context context.Context, duration time.Duration) *CacheClient {
-	if duration == 0 {
+func NewCacheClient(context context.Context, config *Config) *CacheClient {
+	if config.Duration == 0 {
 		panic("duration must be greater than 0")
 	}
 
-	return &CacheClient{
-		context:  context,
-		duration: duration,
-		store:    sync.Map{},
+	c := &CacheClient{
+		context: context,
+		config:  config,
+		entries: make(map[string]*list.Element),
+		users:   make(map[string]map[string]struct{}),
+		lru:     list.New(),
 	}
+
+	if config.SnapshotFile != "" {
+		if config.EncryptionKey != "" {
+			c.aead = newAEAD(config.EncryptionKey)
+		}
+
+		// restore sessions cached before the last restart
+		c.loadSnapshot()
+
+		go c.snapshotter()
+	}
+
+	// start a single janitor that removes expired entries
+	go c.janitor()
+
+	return c
 }
 
-func (c *CacheClient) Get(cookies []*http.Cookie) *Session {
-	sessionId := GetIdentifier(cookies)
-	if v, ok := c.store.Load(sessionId); ok {
-		if s, ok := v.(*Session); ok {
-			return s
+func (c *CacheClient) Get(key string) (*Session, time.Time) {
+	if key == "" {
+		// anonymous sessions are never cached
+		return nil, time.Time{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, time.Time{}
+	}
+
+	entry := el.Value.(*cacheEntry) //nolint:forcetypeassert
+	if c.context.Err() == nil && time.Now().After(entry.expires) {
+		if time.Now().After(entry.expires.Add(c.config.StaleDuration)) {
+			// remove expired entry that was not swept yet
+			c.remove(el)
 		}
+
+		return nil, time.Time{}
+	}
+
+	// mark entry as recently used
+	c.lru.MoveToFront(el)
+
+	return entry.session, entry.expires
+}
+
+func (c *CacheClient) GetStale(key string) *Session {
+	if key == "" {
+		// anonymous sessions are never cached
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil
 	}
 
-	return nil
+	entry := el.Value.(*cacheEntry) //nolint:forcetypeassert
+	if c.context.Err() == nil && time.Now().After(entry.expires.Add(c.config.StaleDuration)) {
+		// remove entry that is past its stale duration
+		c.remove(el)
+		return nil
+	}
+
+	return entry.session
 }
 
-func (c *CacheClient) Set(cookies []*http.Cookie, meta *Session) {
-	sessionId := GetIdentifier(cookies)
-	c.store.Store(sessionId, meta)
-	if c.context.Err() != nil {
+func (c *CacheClient) Set(key string, meta *Session, ttl time.Duration) {
+	if key == "" {
+		// anonymous sessions are never cached, their cookies belong to a single client
 		return
 	}
 
-	go func() {
-		timer := time.NewTimer(c.duration)
-		defer timer.Stop()
+	if ttl > c.config.Duration {
+		// never keep entries longer than the configured duration
+		ttl = c.config.Duration
+	}
+
+	entry := &cacheEntry{
+		key:     key,
+		users:   meta.GetUsers(),
+		session: meta,
+		size:    getEntrySize(key, meta),
+		expires: time.Now().Add(ttl),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+
+	if ttl <= 0 {
+		// session must not be cached
+		return
+	}
+
+	if c.config.MaxBytes > 0 && entry.size > c.config.MaxBytes {
+		// entry would never fit in the cache
+		return
+	}
+
+	c.add(entry)
+}
+
+func (c *CacheClient) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+// DeleteUser deletes every session of the given user identifier, limited to
+// sessions of the given host unless it is empty.
+func (c *CacheClient) DeleteUser(user string, host string) {
+	if user == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.users[user] {
+		el := c.entries[key]
 
+		entry := el.Value.(*cacheEntry) //nolint:forcetypeassert
+		if host == "" || entry.session.Host == host {
+			c.remove(el)
+		}
+	}
+}
+
+func (c *CacheClient) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+func (c *CacheClient) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.size
+}
+
+func (c *CacheClient) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Stats{
+		Entries:   c.lru.Len(),
+		Bytes:     c.size,
+		Evictions: c.evictions,
+	}
+}
+
+func (c *CacheClient) janitor() {
+	ticker := time.NewTicker(c.config.Duration)
+	defer ticker.Stop()
+
+	for {
 		select {
-		case <-timer.C:
-			if c.context.Err() == nil {
-				c.store.Delete(sessionId)
-			}
+		case <-ticker.C:
+			c.sweep(time.Now())
 		case <-c.context.Done():
+			return
+		}
+	}
+}
+
+func (c *CacheClient) sweep(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for el := c.lru.Back(); el != nil; {
+		prev := el.Prev()
+
+		entry := el.Value.(*cacheEntry) //nolint:forcetypeassert
+		if now.After(entry.expires.Add(c.config.StaleDuration)) {
+			c.remove(el)
 		}
-	}()
+
+		el = prev
+	}
 }
 
-func (c *CacheClient) Delete(cookies []*http.Cookie) {
-	sessionId := GetIdentifier(cookies)
-	c.store.Delete(sessionId)
+func (c *CacheClient) isOverLimit() bool {
+	if c.lru.Len() == 0 {
+		return false
+	}
+
+	if c.config.MaxEntries > 0 && c.lru.Len() > c.config.MaxEntries {
+		return true
+	}
+
+	return c.config.MaxBytes > 0 && c.size > c.config.MaxBytes
+}
+
+func (c *CacheClient) add(entry *cacheEntry) {
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	c.size += entry.size
+
+	// index entry by user, so every session of the user can be deleted
+	for _, user := range entry.users {
+		if _, ok := c.users[user]; !ok {
+			c.users[user] = make(map[string]struct{})
+		}
+
+		c.users[user][entry.key] = struct{}{}
+	}
+
+	// evict least recently used entries until the cache is within limits
+	for c.isOverLimit() {
+		c.remove(c.lru.Back())
+		c.evictions++
+	}
+}
+
+func (c *CacheClient) remove(el *list.Element) {
+	entry := el.Value.(*cacheEntry) //nolint:forcetypeassert
+
+	c.lru.Remove(el)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+
+	for _, user := range entry.users {
+		delete(c.users[user], entry.key)
+		if len(c.users[user]) == 0 {
+			delete(c.users, user)
+		}
+	}
+}
+
+func getEntrySize(key string, s *Session) int64 {
+	size := len(key)
+
+	// approximate the memory used by the session headers and cookies
+	for k, vs := range s.Headers {
+		size += len(k)
+		for _, v := range vs {
+			size += len(v)
+		}
+	}
+
+	for _, c := range s.Cookies {
+		size += len(c.Name) + len(c.Value) + len(c.Path) + len(c.Domain)
+	}
+
+	return int64(size)
 }
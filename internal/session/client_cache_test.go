@@ -3,6 +3,7 @@ package session_test
 import (
 	"context"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -18,11 +19,11 @@ func TestNewCacheClient(t *testing.T) {
 			}
 		}()
 
-		session.NewCacheClient(context.Background(), 0)
+		session.NewCacheClient(context.Background(), &session.Config{Duration: 0})
 	})
 
 	t.Run("with duration", func(t *testing.T) {
-		client := session.NewCacheClient(context.Background(), 10*time.Second)
+		client := session.NewCacheClient(context.Background(), &session.Config{Duration: 10 * time.Second})
 
 		// check that the client is not nil
 		if client == nil {
@@ -33,14 +34,9 @@ func TestNewCacheClient(t *testing.T) {
 
 func TestCacheClient(t *testing.T) {
 	t.Run("retrieve without store", func(t *testing.T) {
-		client := session.NewCacheClient(context.Background(), 10*time.Second)
+		client := session.NewCacheClient(context.Background(), &session.Config{Duration: 10 * time.Second})
 
-		session := client.Get([]*http.Cookie{
-			{
-				Name:  "test",
-				Value: "test",
-			},
-		})
+		session, _ := client.Get("test")
 
 		// check that the session is nil
 		if session != nil {
@@ -49,7 +45,7 @@ func TestCacheClient(t *testing.T) {
 	})
 
 	t.Run("retrieve after store", func(t *testing.T) {
-		client := session.NewCacheClient(context.Background(), 10*time.Second)
+		client := session.NewCacheClient(context.Background(), &session.Config{Duration: 10 * time.Second})
 
 		session := &session.Session{
 			IsAuthenticated: true,
@@ -63,20 +59,10 @@ func TestCacheClient(t *testing.T) {
 				},
 			},
 		}
-		client.Set([]*http.Cookie{
-			{
-				Name:  "test",
-				Value: "test",
-			},
-		}, session)
+		client.Set("test", session, 10*time.Second)
 
 		// check that the session is not nil
-		session = client.Get([]*http.Cookie{
-			{
-				Name:  "test",
-				Value: "test",
-			},
-		})
+		session, _ = client.Get("test")
 		if session == nil {
 			t.Fatal("expected session to be not nil")
 		}
@@ -96,7 +82,7 @@ func TestCacheClient(t *testing.T) {
 	})
 
 	t.Run("retrieve after delete", func(t *testing.T) {
-		client := session.NewCacheClient(context.Background(), 10*time.Second)
+		client := session.NewCacheClient(context.Background(), &session.Config{Duration: 10 * time.Second})
 
 		session := &session.Session{
 			IsAuthenticated: true,
@@ -108,56 +94,31 @@ func TestCacheClient(t *testing.T) {
 				},
 			},
 		}
-		client.Set([]*http.Cookie{
-			{
-				Name:  "test",
-				Value: "test",
-			},
-		}, session)
-		client.Delete([]*http.Cookie{
-			{
-				Name:  "test",
-				Value: "test",
-			},
-		})
+		client.Set("test", session, 10*time.Second)
+		client.Delete("test")
 
 		// check that the session is nil
-		session = client.Get([]*http.Cookie{
-			{
-				Name:  "test",
-				Value: "test",
-			},
-		})
+		session, _ = client.Get("test")
 		if session != nil {
 			t.Errorf("expected session to be nil")
 		}
 	})
 
 	t.Run("retrieve after expiration", func(t *testing.T) {
-		client := session.NewCacheClient(context.Background(), 10*time.Millisecond)
+		client := session.NewCacheClient(context.Background(), &session.Config{Duration: 10 * time.Millisecond})
 
 		session := &session.Session{
 			IsAuthenticated: true,
 			Headers:         http.Header{},
 			Cookies:         []*http.Cookie{},
 		}
-		client.Set([]*http.Cookie{
-			{
-				Name:  "test",
-				Value: "test",
-			},
-		}, session)
+		client.Set("test", session, 10*time.Millisecond)
 
 		// wait for the session to expire
 		time.Sleep(30 * time.Millisecond)
 
 		// check that the session is nil
-		session = client.Get([]*http.Cookie{
-			{
-				Name:  "test",
-				Value: "test",
-			},
-		})
+		session, _ = client.Get("test")
 		if session != nil {
 			t.Errorf("expected session to be nil")
 		}
@@ -165,7 +126,7 @@ func TestCacheClient(t *testing.T) {
 
 	t.Run("retrieve after expiration cancel", func(t *testing.T) {
 		ctx, cancel := context.WithCancel(context.Background())
-		client := session.NewCacheClient(ctx, 10*time.Millisecond)
+		client := session.NewCacheClient(ctx, &session.Config{Duration: 10 * time.Millisecond})
 
 		// cancel the context
 		cancel()
@@ -175,47 +136,374 @@ func TestCacheClient(t *testing.T) {
 			Headers:         http.Header{},
 			Cookies:         []*http.Cookie{},
 		}
-		client.Set([]*http.Cookie{
-			{
-				Name:  "test",
-				Value: "test",
-			},
-		}, session)
+		client.Set("test", session, 10*time.Millisecond)
 
 		// wait for the session to expire
 		time.Sleep(30 * time.Millisecond)
 
 		// check that the session is not nil
-		session = client.Get([]*http.Cookie{
-			{
-				Name:  "test",
-				Value: "test",
-			},
-		})
+		session, _ = client.Get("test")
 		if session == nil {
 			t.Errorf("expected session to be not nil")
 		}
 	})
 
 	t.Run("delete before store", func(t *testing.T) {
-		client := session.NewCacheClient(context.Background(), 10*time.Second)
+		client := session.NewCacheClient(context.Background(), &session.Config{Duration: 10 * time.Second})
 
-		client.Delete([]*http.Cookie{
-			{
-				Name:  "test",
-				Value: "test",
-			},
-		})
+		client.Delete("test")
 
 		// check that the session is nil
-		session := client.Get([]*http.Cookie{
-			{
-				Name:  "test",
-				Value: "test",
-			},
-		})
+		session, _ := client.Get("test")
 		if session != nil {
 			t.Errorf("expected session to be nil")
 		}
 	})
 }
+
+func TestCacheClient_Limits(t *testing.T) {
+	t.Run("evict least recently used entry", func(t *testing.T) {
+		client := session.NewCacheClient(context.Background(), &session.Config{
+			Duration:   10 * time.Second,
+			MaxEntries: 2,
+		})
+
+		first := "first"
+		second := "second"
+		third := "third"
+
+		client.Set(first, &session.Session{IsAuthenticated: true}, 10*time.Second)
+		client.Set(second, &session.Session{IsAuthenticated: true}, 10*time.Second)
+
+		// mark the first entry as recently used
+		if s, _ := client.Get(first); s == nil {
+			t.Fatal("expected first session to be not nil")
+		}
+
+		client.Set(third, &session.Session{IsAuthenticated: true}, 10*time.Second)
+
+		// check that the cache is within the entry limit
+		expectedLen := 2
+		if client.Len() != expectedLen {
+			t.Errorf("expected %d entries, got %d", expectedLen, client.Len())
+		}
+
+		// check that the least recently used entry was evicted
+		if s, _ := client.Get(second); s != nil {
+			t.Errorf("expected second session to be nil")
+		}
+
+		if s, _ := client.Get(first); s == nil {
+			t.Errorf("expected first session to be not nil")
+		}
+
+		if s, _ := client.Get(third); s == nil {
+			t.Errorf("expected third session to be not nil")
+		}
+	})
+
+	t.Run("evict entries over byte budget", func(t *testing.T) {
+		client := session.NewCacheClient(context.Background(), &session.Config{
+			Duration: 10 * time.Second,
+			MaxBytes: 200,
+		})
+
+		first := "first"
+		second := "second"
+
+		client.Set(first, &session.Session{
+			IsAuthenticated: true,
+			Headers:         http.Header{"X-Test": []string{strings.Repeat("a", 100)}},
+		}, 10*time.Second)
+		client.Set(second, &session.Session{
+			IsAuthenticated: true,
+			Headers:         http.Header{"X-Test": []string{strings.Repeat("b", 100)}},
+		}, 10*time.Second)
+
+		// check that the cache is within the byte budget
+		if client.Size() > 200 {
+			t.Errorf("expected size to be at most 200, got %d", client.Size())
+		}
+
+		// check that the oldest entry was evicted
+		if s, _ := client.Get(first); s != nil {
+			t.Errorf("expected first session to be nil")
+		}
+
+		if s, _ := client.Get(second); s == nil {
+			t.Errorf("expected second session to be not nil")
+		}
+	})
+
+	t.Run("skip entry bigger than byte budget", func(t *testing.T) {
+		client := session.NewCacheClient(context.Background(), &session.Config{
+			Duration: 10 * time.Second,
+			MaxBytes: 10,
+		})
+
+		key := "test"
+		client.Set(key, &session.Session{
+			IsAuthenticated: true,
+			Headers:         http.Header{"X-Test": []string{"test"}},
+		}, 10*time.Second)
+
+		// check that the session was not stored
+		if s, _ := client.Get(key); s != nil {
+			t.Errorf("expected session to be nil")
+		}
+	})
+
+	t.Run("sweep expired entries", func(t *testing.T) {
+		client := session.NewCacheClient(context.Background(), &session.Config{
+			Duration: 10 * time.Millisecond,
+		})
+
+		client.Set("first", &session.Session{}, 10*time.Millisecond)
+		client.Set("second", &session.Session{}, 10*time.Millisecond)
+
+		// wait for the janitor to sweep the entries
+		time.Sleep(50 * time.Millisecond)
+
+		// check that the entries were removed without being accessed
+		if client.Len() != 0 {
+			t.Errorf("expected 0 entries, got %d", client.Len())
+		}
+
+		if client.Size() != 0 {
+			t.Errorf("expected size to be 0, got %d", client.Size())
+		}
+	})
+}
+
+func TestCacheClient_TTL(t *testing.T) {
+	t.Run("skip entry with zero ttl", func(t *testing.T) {
+		client := session.NewCacheClient(context.Background(), &session.Config{Duration: 10 * time.Second})
+
+		key := "test"
+		client.Set(key, &session.Session{IsAuthenticated: true}, 10*time.Second)
+		client.Set(key, &session.Session{IsAuthenticated: false}, 0)
+
+		// check that the previous session was removed and the new one was not stored
+		if s, _ := client.Get(key); s != nil {
+			t.Errorf("expected session to be nil")
+		}
+	})
+
+	t.Run("expire entries with their own ttl", func(t *testing.T) {
+		client := session.NewCacheClient(context.Background(), &session.Config{Duration: 10 * time.Second})
+
+		short := "short"
+		long := "long"
+
+		client.Set(short, &session.Session{IsAuthenticated: false}, 10*time.Millisecond)
+		client.Set(long, &session.Session{IsAuthenticated: true}, 10*time.Second)
+
+		// wait for the short session to expire
+		time.Sleep(30 * time.Millisecond)
+
+		// check that only the short session expired
+		if s, _ := client.Get(short); s != nil {
+			t.Errorf("expected short session to be nil")
+		}
+
+		if s, _ := client.Get(long); s == nil {
+			t.Errorf("expected long session to be not nil")
+		}
+	})
+
+	t.Run("retrieve entry expiration", func(t *testing.T) {
+		client := session.NewCacheClient(context.Background(), &session.Config{Duration: time.Minute})
+
+		key := "test"
+
+		before := time.Now()
+		client.Set(key, &session.Session{IsAuthenticated: true}, 10*time.Second)
+		after := time.Now()
+
+		// check that the expiration matches the entry ttl
+		_, expires := client.Get(key)
+		if expires.Before(before.Add(10*time.Second)) || expires.After(after.Add(10*time.Second)) {
+			t.Errorf("expected expiration to be 10s after store, got %v", expires.Sub(before))
+		}
+	})
+
+	t.Run("cap ttl to configured duration", func(t *testing.T) {
+		client := session.NewCacheClient(context.Background(), &session.Config{Duration: 10 * time.Millisecond})
+
+		key := "test"
+		client.Set(key, &session.Session{IsAuthenticated: true}, time.Hour)
+
+		// wait for the configured duration to elapse
+		time.Sleep(30 * time.Millisecond)
+
+		// check that the session expired
+		if s, _ := client.Get(key); s != nil {
+			t.Errorf("expected session to be nil")
+		}
+	})
+}
+
+func TestCacheClient_Stale(t *testing.T) {
+	t.Run("retrieve stale entry inside stale duration", func(t *testing.T) {
+		client := session.NewCacheClient(context.Background(), &session.Config{
+			Duration:      10 * time.Millisecond,
+			StaleDuration: 10 * time.Second,
+		})
+
+		key := "test"
+		client.Set(key, &session.Session{IsAuthenticated: true}, 10*time.Millisecond)
+
+		// wait for the session to expire
+		time.Sleep(30 * time.Millisecond)
+
+		// check that the session is not fresh
+		if s, _ := client.Get(key); s != nil {
+			t.Errorf("expected session to be nil")
+		}
+
+		// check that the session is still available as stale
+		if s := client.GetStale(key); s == nil {
+			t.Errorf("expected stale session to be not nil")
+		}
+	})
+
+	t.Run("retrieve stale entry outside stale duration", func(t *testing.T) {
+		client := session.NewCacheClient(context.Background(), &session.Config{
+			Duration:      10 * time.Millisecond,
+			StaleDuration: 10 * time.Millisecond,
+		})
+
+		key := "test"
+		client.Set(key, &session.Session{IsAuthenticated: true}, 10*time.Millisecond)
+
+		// wait for the stale duration to elapse
+		time.Sleep(50 * time.Millisecond)
+
+		// check that the session is not available as stale
+		if s := client.GetStale(key); s != nil {
+			t.Errorf("expected stale session to be nil")
+		}
+	})
+}
+
+func TestCacheClient_Anonymous(t *testing.T) {
+	t.Run("skip anonymous session", func(t *testing.T) {
+		client := session.NewCacheClient(context.Background(), &session.Config{Duration: 10 * time.Second})
+
+		key := session.GetIdentifier([]*http.Cookie{}, "example.com")
+		client.Set(key, &session.Session{
+			IsAuthenticated: false,
+			Cookies:         []*http.Cookie{{Name: "authentik_proxy_state", Value: "first"}},
+		}, 10*time.Second)
+
+		// check that the anonymous session was not stored
+		if client.Len() != 0 {
+			t.Errorf("expected 0 entries, got %d", client.Len())
+		}
+	})
+
+	t.Run("isolate anonymous clients", func(t *testing.T) {
+		client := session.NewCacheClient(context.Background(), &session.Config{
+			Duration:      10 * time.Second,
+			StaleDuration: 10 * time.Second,
+		})
+
+		// first anonymous client receives state cookies
+		firstKey := session.GetIdentifier(nil, "example.com")
+		client.Set(firstKey, &session.Session{
+			IsAuthenticated: false,
+			Cookies:         []*http.Cookie{{Name: "authentik_proxy_state", Value: "first"}},
+		}, 10*time.Second)
+
+		// check that a second anonymous client never gets the first client cookies
+		secondKey := session.GetIdentifier([]*http.Cookie{}, "example.com")
+		if s, _ := client.Get(secondKey); s != nil {
+			t.Errorf("expected session to be nil, got cookies %v", s.Cookies)
+		}
+
+		if s := client.GetStale(secondKey); s != nil {
+			t.Errorf("expected stale session to be nil, got cookies %v", s.Cookies)
+		}
+	})
+
+	t.Run("isolate anonymous client from identified client", func(t *testing.T) {
+		client := session.NewCacheClient(context.Background(), &session.Config{Duration: 10 * time.Second})
+
+		identifiedKey := session.GetIdentifier([]*http.Cookie{{Name: "authentik_proxy_session", Value: "test"}}, "example.com")
+		client.Set(identifiedKey, &session.Session{IsAuthenticated: true}, 10*time.Second)
+
+		// check that an anonymous client does not get the identified session
+		anonymousKey := session.GetIdentifier(nil, "example.com")
+		if s, _ := client.Get(anonymousKey); s != nil {
+			t.Errorf("expected session to be nil")
+		}
+
+		// check that the identified session is still cached
+		if s, _ := client.Get(identifiedKey); s == nil {
+			t.Errorf("expected session to be not nil")
+		}
+	})
+}
+
+func TestCacheClient_DeleteUser(t *testing.T) {
+	newSession := func(uid string, host string) *session.Session {
+		return &session.Session{
+			IsAuthenticated: true,
+			Host:            host,
+			Headers:         http.Header{"X-Authentik-Uid": []string{uid}},
+		}
+	}
+
+	t.Run("delete every session of the user", func(t *testing.T) {
+		client := session.NewCacheClient(context.Background(), &session.Config{Duration: 10 * time.Second})
+
+		client.Set("first", newSession("user", "a.example.com"), 10*time.Second)
+		client.Set("second", newSession("user", "b.example.com"), 10*time.Second)
+		client.Set("other", newSession("other", "a.example.com"), 10*time.Second)
+
+		client.DeleteUser(session.UserByUID("user"), "")
+
+		// check that only the sessions of the other user are kept
+		if client.Len() != 1 {
+			t.Errorf("expected 1 entry, got %d", client.Len())
+		}
+
+		if s, _ := client.Get("other"); s == nil {
+			t.Error("expected session of the other user to be kept")
+		}
+	})
+
+	t.Run("delete sessions of the user on a host", func(t *testing.T) {
+		client := session.NewCacheClient(context.Background(), &session.Config{Duration: 10 * time.Second})
+
+		client.Set("first", newSession("user", "a.example.com"), 10*time.Second)
+		client.Set("second", newSession("user", "b.example.com"), 10*time.Second)
+
+		client.DeleteUser(session.UserByUID("user"), "a.example.com")
+
+		if s, _ := client.Get("first"); s != nil {
+			t.Error("expected session on the host to be deleted")
+		}
+
+		if s, _ := client.Get("second"); s == nil {
+			t.Error("expected session on another host to be kept")
+		}
+	})
+
+	t.Run("keep index in sync with evictions", func(t *testing.T) {
+		client := session.NewCacheClient(context.Background(), &session.Config{
+			Duration:   10 * time.Second,
+			MaxEntries: 1,
+		})
+
+		client.Set("first", newSession("user", "example.com"), 10*time.Second)
+		client.Set("second", newSession("other", "example.com"), 10*time.Second)
+
+		// check that deleting an evicted session does not fail
+		client.DeleteUser(session.UserByUID("user"), "")
+
+		if s, _ := client.Get("second"); s == nil {
+			t.Error("expected session of the other user to be kept")
+		}
+	})
+}
@@ -0,0 +1,176 @@
+package session
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/redis"
+)
+
+type RedisClient struct {
+	context context.Context //nolint:containedctx
+	config  *Config
+	redis   *redis.Client
+	aead    cipher.AEAD
+}
+
+type redisRecord struct {
+	Session *Session  `json:"session"`
+	Expires time.Time `json:"expires"`
+}
+
+func NewRedisClient(context context.Context, config *Config) *RedisClient {
+	if config.Duration == 0 {
+		panic("session duration must be greater than 0")
+	}
+
+	c := &RedisClient{
+		context: context,
+		config:  config,
+		redis:   redis.New(context, config.Redis),
+	}
+
+	if config.EncryptionKey != "" {
+		c.aead = newAEAD(config.EncryptionKey)
+	}
+
+	return c
+}
+
+func (c *RedisClient) Get(key string) (*Session, time.Time) {
+	record := c.get(key)
+	if record == nil || time.Now().After(record.Expires) {
+		return nil, time.Time{}
+	}
+
+	return record.Session, record.Expires
+}
+
+func (c *RedisClient) GetStale(key string) *Session {
+	record := c.get(key)
+	if record == nil {
+		return nil
+	}
+
+	return record.Session
+}
+
+func (c *RedisClient) Set(key string, meta *Session, ttl time.Duration) {
+	if key == "" {
+		// anonymous sessions are never cached
+		return
+	}
+
+	ttl = min(ttl, c.config.Duration)
+	if ttl <= 0 {
+		c.Delete(key)
+		return
+	}
+
+	data, err := json.Marshal(&redisRecord{
+		Session: meta,
+		Expires: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return
+	}
+
+	data, err = seal(c.aead, data)
+	if err != nil {
+		return
+	}
+
+	// keep the record in redis while it can still be served as stale
+	if err := c.redis.Set(c.context, c.config.Prefix+key, string(data), ttl+c.config.StaleDuration); err != nil {
+		return
+	}
+
+	// index record by user, the index lives as long as its longest lived record
+	for _, user := range meta.GetUsers() {
+		index := c.getUserKey(user)
+
+		_ = c.redis.SAdd(c.context, index, meta.Host+"\n"+key)
+
+		// never shorten the index lifetime, or purges would miss older records
+		if current, err := c.redis.PTTL(c.context, index); err == nil && current >= ttl+c.config.StaleDuration {
+			continue
+		}
+
+		_ = c.redis.PExpire(c.context, index, ttl+c.config.StaleDuration)
+	}
+}
+
+func (c *RedisClient) Delete(key string) {
+	if key == "" {
+		return
+	}
+
+	_ = c.redis.Del(c.context, c.config.Prefix+key)
+}
+
+// DeleteUser deletes every session of the given user identifier, limited to
+// sessions of the given host unless it is empty.
+func (c *RedisClient) DeleteUser(user string, host string) {
+	if user == "" {
+		return
+	}
+
+	index := c.getUserKey(user)
+
+	members, err := c.redis.SMembers(c.context, index)
+	if err != nil {
+		return
+	}
+
+	for _, member := range members {
+		memberHost, key, ok := strings.Cut(member, "\n")
+		if !ok || (host != "" && memberHost != host) {
+			continue
+		}
+
+		if err := c.redis.Del(c.context, c.config.Prefix+key); err == nil {
+			_ = c.redis.SRem(c.context, index, member)
+		}
+	}
+}
+
+// Stats returns no statistics, as entries are kept by redis.
+func (c *RedisClient) Stats() Stats {
+	return Stats{}
+}
+
+func (c *RedisClient) getUserKey(user string) string {
+	// user identifiers are not stored in clear
+	hash := sha256.Sum256([]byte(user))
+
+	return c.config.Prefix + "user:" + hex.EncodeToString(hash[:])
+}
+
+func (c *RedisClient) get(key string) *redisRecord {
+	if key == "" {
+		return nil
+	}
+
+	// redis failures are handled as cache misses
+	value, ok, err := c.redis.Get(c.context, c.config.Prefix+key)
+	if err != nil || !ok {
+		return nil
+	}
+
+	data, err := open(c.aead, []byte(value))
+	if err != nil {
+		return nil
+	}
+
+	var record redisRecord
+	if err := json.Unmarshal(data, &record); err != nil || record.Session == nil {
+		return nil
+	}
+
+	return &record
+}
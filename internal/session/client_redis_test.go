@@ -0,0 +1,256 @@
+package session_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/redis"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/redis/redistest"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/session"
+)
+
+func newRedisConfig(server *redistest.Server) *session.Config {
+	return &session.Config{
+		Duration: 10 * time.Second,
+		Backend:  session.BackendRedis,
+		Redis:    &redis.Config{Address: server.Address, Timeout: time.Second},
+		Prefix:   "test:",
+	}
+}
+
+func TestRedisClient(t *testing.T) {
+	t.Run("store and retrieve", func(t *testing.T) {
+		server := redistest.NewServer()
+		defer server.Close()
+
+		client := session.NewRedisClient(context.Background(), newRedisConfig(server))
+
+		client.Set("test", &session.Session{
+			IsAuthenticated: true,
+			Headers:         http.Header{"X-Authentik-Username": []string{"user"}},
+			Cookies:         []*http.Cookie{{Name: "authentik_session", Value: "value"}},
+		}, time.Minute)
+
+		s, expires := client.Get("test")
+		if s == nil {
+			t.Fatal("expected session to be not nil")
+		}
+
+		if !s.IsAuthenticated {
+			t.Error("expected session to be authenticated")
+		}
+
+		if s.Headers.Get("X-Authentik-Username") != "user" {
+			t.Errorf("expected username header to be user, got %s", s.Headers.Get("X-Authentik-Username"))
+		}
+
+		if len(s.Cookies) != 1 || s.Cookies[0].Value != "value" {
+			t.Errorf("expected session cookie to be preserved, got %v", s.Cookies)
+		}
+
+		// ttl is capped to the cache duration
+		if time.Until(expires) > 10*time.Second {
+			t.Errorf("expected expiration to be capped, got %v", time.Until(expires))
+		}
+
+		if _, ok := server.Value("test:test"); !ok {
+			t.Error("expected key to be prefixed")
+		}
+	})
+
+	t.Run("shared between clients", func(t *testing.T) {
+		server := redistest.NewServer()
+		defer server.Close()
+
+		first := session.NewRedisClient(context.Background(), newRedisConfig(server))
+		second := session.NewRedisClient(context.Background(), newRedisConfig(server))
+
+		first.Set("test", &session.Session{IsAuthenticated: true}, time.Minute)
+
+		if s, _ := second.Get("test"); s == nil {
+			t.Fatal("expected session to be shared")
+		}
+
+		second.Delete("test")
+
+		if s, _ := first.Get("test"); s != nil {
+			t.Error("expected session to be deleted for every client")
+		}
+	})
+
+	t.Run("with stale duration", func(t *testing.T) {
+		server := redistest.NewServer()
+		defer server.Close()
+
+		config := newRedisConfig(server)
+		config.StaleDuration = time.Minute
+
+		client := session.NewRedisClient(context.Background(), config)
+		client.Set("test", &session.Session{IsAuthenticated: true}, 20*time.Millisecond)
+
+		time.Sleep(40 * time.Millisecond)
+
+		if s, _ := client.Get("test"); s != nil {
+			t.Error("expected session to be expired")
+		}
+
+		if s := client.GetStale("test"); s == nil {
+			t.Error("expected stale session to be kept")
+		}
+	})
+
+	t.Run("with encryption", func(t *testing.T) {
+		server := redistest.NewServer()
+		defer server.Close()
+
+		config := newRedisConfig(server)
+		config.EncryptionKey = "secret"
+
+		client := session.NewRedisClient(context.Background(), config)
+		client.Set("test", &session.Session{
+			IsAuthenticated: true,
+			Headers:         http.Header{"X-Authentik-Username": []string{"user"}},
+		}, time.Minute)
+
+		value, _ := server.Value("test:test")
+		if strings.Contains(value, "X-Authentik-Username") {
+			t.Error("expected stored session to be encrypted")
+		}
+
+		if s, _ := client.Get("test"); s == nil {
+			t.Fatal("expected session to be decrypted")
+		}
+
+		config = newRedisConfig(server)
+		config.EncryptionKey = "other"
+
+		other := session.NewRedisClient(context.Background(), config)
+		if s, _ := other.Get("test"); s != nil {
+			t.Error("expected session with a different key to be ignored")
+		}
+	})
+
+	t.Run("with anonymous key", func(t *testing.T) {
+		server := redistest.NewServer()
+		defer server.Close()
+
+		client := session.NewRedisClient(context.Background(), newRedisConfig(server))
+		client.Set("", &session.Session{IsAuthenticated: false}, time.Minute)
+
+		if keys := server.Keys(); len(keys) != 0 {
+			t.Errorf("expected no keys, got %v", keys)
+		}
+	})
+
+	t.Run("with server unavailable", func(t *testing.T) {
+		server := redistest.NewServer()
+		server.Close()
+
+		client := session.NewRedisClient(context.Background(), newRedisConfig(server))
+		client.Set("test", &session.Session{IsAuthenticated: true}, time.Minute)
+
+		if s, _ := client.Get("test"); s != nil {
+			t.Error("expected a cache miss")
+		}
+	})
+}
+
+func TestRedisClient_DeleteUser(t *testing.T) {
+	newSession := func(uid string, host string) *session.Session {
+		return &session.Session{
+			IsAuthenticated: true,
+			Host:            host,
+			Headers:         http.Header{"X-Authentik-Uid": []string{uid}},
+		}
+	}
+
+	t.Run("delete every session of the user", func(t *testing.T) {
+		server := redistest.NewServer()
+		defer server.Close()
+
+		client := session.NewRedisClient(context.Background(), newRedisConfig(server))
+
+		client.Set("first", newSession("user", "a.example.com"), time.Minute)
+		client.Set("second", newSession("user", "b.example.com"), time.Minute)
+		client.Set("other", newSession("other", "a.example.com"), time.Minute)
+
+		client.DeleteUser(session.UserByUID("user"), "")
+
+		if s, _ := client.Get("first"); s != nil {
+			t.Error("expected first session to be deleted")
+		}
+
+		if s, _ := client.Get("second"); s != nil {
+			t.Error("expected second session to be deleted")
+		}
+
+		if s, _ := client.Get("other"); s == nil {
+			t.Error("expected session of the other user to be kept")
+		}
+	})
+
+	t.Run("delete sessions of the user on a host", func(t *testing.T) {
+		server := redistest.NewServer()
+		defer server.Close()
+
+		client := session.NewRedisClient(context.Background(), newRedisConfig(server))
+
+		client.Set("first", newSession("user", "a.example.com"), time.Minute)
+		client.Set("second", newSession("user", "b.example.com"), time.Minute)
+
+		client.DeleteUser(session.UserByUID("user"), "a.example.com")
+
+		if s, _ := client.Get("first"); s != nil {
+			t.Error("expected session on the host to be deleted")
+		}
+
+		if s, _ := client.Get("second"); s == nil {
+			t.Error("expected session on another host to be kept")
+		}
+	})
+
+	t.Run("with records of different ttls", func(t *testing.T) {
+		server := redistest.NewServer()
+		defer server.Close()
+
+		client := session.NewRedisClient(context.Background(), newRedisConfig(server))
+
+		client.Set("first", newSession("user", "example.com"), 5*time.Second)
+		client.Set("second", newSession("user", "example.com"), time.Second)
+
+		// check that the shorter record did not shorten the index
+		for _, key := range server.Keys() {
+			if !strings.Contains(key, "user") {
+				continue
+			}
+
+			if ttl, ok := server.TTL(key); !ok || ttl <= time.Second {
+				t.Errorf("expected index %s to live as long as the first record, got %v", key, ttl)
+			}
+		}
+
+		client.DeleteUser(session.UserByUID("user"), "")
+
+		if s, _ := client.Get("first"); s != nil {
+			t.Error("expected first session to be deleted")
+		}
+	})
+
+	t.Run("expire user index", func(t *testing.T) {
+		server := redistest.NewServer()
+		defer server.Close()
+
+		client := session.NewRedisClient(context.Background(), newRedisConfig(server))
+		client.Set("first", newSession("user", "example.com"), time.Minute)
+
+		// check that the index never outlives the configured duration
+		for _, key := range server.Keys() {
+			if ttl, ok := server.TTL(key); !ok || ttl > 10*time.Second {
+				t.Errorf("expected key %s to expire within the cache duration, got %v", key, ttl)
+			}
+		}
+	})
+}
@@ -1,6 +1,6 @@
 package session
 
-import "net/http"
+import "time"
 
 type StandardClient struct {
 }
@@ -9,12 +9,23 @@ func NewStandardClient() *StandardClient {
 	return &StandardClient{}
 }
 
-func (c *StandardClient) Get(cookies []*http.Cookie) *Session {
+func (c *StandardClient) Get(key string) (*Session, time.Time) {
+	return nil, time.Time{}
+}
+
+func (c *StandardClient) GetStale(key string) *Session {
 	return nil
 }
 
-func (c *StandardClient) Set(cookies []*http.Cookie, meta *Session) {
+func (c *StandardClient) Set(key string, meta *Session, ttl time.Duration) {
+}
+
+func (c *StandardClient) Delete(key string) {
+}
+
+func (c *StandardClient) DeleteUser(user string, host string) {
 }
 
-func (c *StandardClient) Delete(cookies []*http.Cookie) {
+func (c *StandardClient) Stats() Stats {
+	return Stats{}
 }
@@ -10,7 +10,7 @@ import (
 
 func TestNewClient(t *testing.T) {
 	t.Run("with no duration", func(t *testing.T) {
-		client := session.NewClient(context.Background(), 0)
+		client := session.NewClient(context.Background(), &session.Config{Duration: 0})
 
 		// check that the client is not nil
 		if client == nil {
@@ -24,7 +24,7 @@ func TestNewClient(t *testing.T) {
 	})
 
 	t.Run("with duration", func(t *testing.T) {
-		client := session.NewClient(context.Background(), 10*time.Second)
+		client := session.NewClient(context.Background(), &session.Config{Duration: 10 * time.Second})
 
 		// check that the client is not nil
 		if client == nil {
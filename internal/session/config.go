@@ -0,0 +1,27 @@
+package session
+
+import (
+	"time"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/redis"
+)
+
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+type Config struct {
+	Duration      time.Duration
+	StaleDuration time.Duration
+	MaxEntries    int
+	MaxBytes      int64
+
+	SnapshotFile     string
+	SnapshotInterval time.Duration
+
+	Backend       string
+	Redis         *redis.Config
+	Prefix        string
+	EncryptionKey string
+}
@@ -0,0 +1,59 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+)
+
+var errInvalidRecord = errors.New("invalid session record")
+
+// newAEAD returns an AES-GCM cipher with a 256 bits key derived from secret.
+func newAEAD(secret string) cipher.AEAD {
+	key := sha256.Sum256([]byte(secret))
+
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		panic(err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+
+	return aead
+}
+
+// seal encrypts data, prepending the random nonce used. Data is returned as
+// is if aead is nil.
+func seal(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if aead == nil {
+		return data, nil
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, data, nil), nil
+}
+
+// open decrypts data sealed with seal.
+func open(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if aead == nil {
+		return data, nil
+	}
+
+	if len(data) < aead.NonceSize() {
+		return nil, errInvalidRecord
+	}
+
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
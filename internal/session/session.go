@@ -5,15 +5,96 @@ import (
 	"encoding/hex"
 	"net/http"
 	"sort"
+	"strings"
+)
+
+const (
+	uidHeaderKey      = "X-Authentik-Uid"
+	usernameHeaderKey = "X-Authentik-Username"
+	groupsHeaderKey   = "X-Authentik-Groups"
 )
 
 type Session struct {
 	IsAuthenticated bool
+	Host            string
 	Headers         http.Header
 	Cookies         []*http.Cookie
 }
 
-func GetIdentifier(cookies []*http.Cookie) string {
+// GetUID returns the authentik uid of the user of an authenticated session.
+func (s *Session) GetUID() string {
+	if !s.IsAuthenticated {
+		return ""
+	}
+
+	return s.Headers.Get(uidHeaderKey)
+}
+
+// GetUsername returns the username of the user of an authenticated session.
+func (s *Session) GetUsername() string {
+	if !s.IsAuthenticated {
+		return ""
+	}
+
+	return s.Headers.Get(usernameHeaderKey)
+}
+
+// GetUsers returns the identifiers of the authentik user of an authenticated
+// session, used to index the session by user.
+func (s *Session) GetUsers() []string {
+	if !s.IsAuthenticated {
+		return nil
+	}
+
+	var users []string
+	if user := UserByUID(s.GetUID()); user != "" {
+		users = append(users, user)
+	}
+
+	if user := UserByUsername(s.GetUsername()); user != "" {
+		users = append(users, user)
+	}
+
+	return users
+}
+
+// GetGroups returns the authentik groups of the user of an authenticated
+// session.
+func (s *Session) GetGroups() []string {
+	if !s.IsAuthenticated {
+		return nil
+	}
+
+	// groups are sent as a pipe separated list
+	var groups []string
+	for _, group := range strings.Split(s.Headers.Get(groupsHeaderKey), "|") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+
+	return groups
+}
+
+// UserByUID returns the identifier of the user with the given authentik uid.
+func UserByUID(uid string) string {
+	if uid == "" {
+		return ""
+	}
+
+	return "uid:" + uid
+}
+
+// UserByUsername returns the identifier of the user with the given username.
+func UserByUsername(username string) string {
+	if username == "" {
+		return ""
+	}
+
+	return "username:" + username
+}
+
+func GetIdentifier(cookies []*http.Cookie, partitions ...string) string {
 	if len(cookies) == 0 {
 		return ""
 	}
@@ -34,6 +115,11 @@ func GetIdentifier(cookies []*http.Cookie) string {
 		concat += p
 	}
 
+	// Scope the identifier to the given partitions
+	for _, p := range partitions {
+		concat += "\n" + p
+	}
+
 	hash := sha256.Sum256([]byte(concat))
 	return hex.EncodeToString(hash[:])
-}
\ No newline at end of file
+}
@@ -0,0 +1,52 @@
+package session_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/session"
+)
+
+func TestGetIdentifier(t *testing.T) {
+	t.Run("with no cookies", func(t *testing.T) {
+		id := session.GetIdentifier([]*http.Cookie{}, "example.com")
+
+		// check that the identifier is empty
+		if id != "" {
+			t.Errorf("expected identifier to be empty, got %s", id)
+		}
+	})
+
+	t.Run("with cookies in different order", func(t *testing.T) {
+		first := session.GetIdentifier([]*http.Cookie{
+			{Name: "a", Value: "1"},
+			{Name: "b", Value: "2"},
+		})
+		second := session.GetIdentifier([]*http.Cookie{
+			{Name: "b", Value: "2"},
+			{Name: "a", Value: "1"},
+		})
+
+		// check that the identifiers are equal
+		if first != second {
+			t.Errorf("expected identifiers to be equal, got %s and %s", first, second)
+		}
+	})
+
+	t.Run("with different partitions", func(t *testing.T) {
+		cookies := []*http.Cookie{{Name: "a", Value: "1"}}
+
+		first := session.GetIdentifier(cookies, "first.example.com")
+		second := session.GetIdentifier(cookies, "second.example.com")
+
+		// check that the identifiers are different
+		if first == second {
+			t.Errorf("expected identifiers to be different")
+		}
+
+		// check that the partition is part of the identifier
+		if first == session.GetIdentifier(cookies) {
+			t.Errorf("expected partitioned identifier to differ from unpartitioned one")
+		}
+	})
+}
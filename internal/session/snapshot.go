@@ -0,0 +1,190 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var ErrSnapshotInUse = errors.New("snapshot file is used by another cache")
+
+type snapshotEntry struct {
+	Key     string    `json:"key"`
+	Session *Session  `json:"session"`
+	Expires time.Time `json:"expires"`
+}
+
+type snapshotClaim struct {
+	owner string
+	refs  int
+}
+
+//nolint:gochecknoglobals
+var snapshotClaims = struct {
+	mu      sync.Mutex
+	entries map[string]*snapshotClaim
+}{entries: make(map[string]*snapshotClaim)}
+
+// ClaimSnapshotFile reserves a snapshot file for an owner until ctx is done,
+// so that caches of different owners never overwrite each other's snapshots.
+// The same owner can claim the file again, as when traefik reloads a
+// middleware while the previous instance is still running.
+func ClaimSnapshotFile(ctx context.Context, path string, owner string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	snapshotClaims.mu.Lock()
+	defer snapshotClaims.mu.Unlock()
+
+	claim, ok := snapshotClaims.entries[path]
+	if !ok {
+		claim = &snapshotClaim{owner: owner}
+		snapshotClaims.entries[path] = claim
+	} else if claim.owner != owner {
+		return fmt.Errorf("%w: %s", ErrSnapshotInUse, path)
+	}
+
+	claim.refs++
+
+	go func() {
+		<-ctx.Done()
+
+		snapshotClaims.mu.Lock()
+		defer snapshotClaims.mu.Unlock()
+
+		claim.refs--
+		if claim.refs == 0 && snapshotClaims.entries[path] == claim {
+			delete(snapshotClaims.entries, path)
+		}
+	}()
+
+	return nil
+}
+
+func (c *CacheClient) snapshotter() {
+	var tick <-chan time.Time
+	if c.config.SnapshotInterval > 0 {
+		ticker := time.NewTicker(c.config.SnapshotInterval)
+		defer ticker.Stop()
+
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+			_ = c.WriteSnapshot()
+		case <-c.context.Done():
+			// persist the cache when the plugin is discarded
+			_ = c.WriteSnapshot()
+			return
+		}
+	}
+}
+
+// WriteSnapshot writes the cached sessions to the snapshot file, encrypted if
+// an encryption key is configured.
+func (c *CacheClient) WriteSnapshot() error {
+	c.mu.Lock()
+	entries := make([]*snapshotEntry, 0, c.lru.Len())
+
+	// store least recently used entries first, so they are restored in order
+	for el := c.lru.Back(); el != nil; el = el.Prev() {
+		entry := el.Value.(*cacheEntry) //nolint:forcetypeassert
+		entries = append(entries, &snapshotEntry{
+			Key:     entry.key,
+			Session: entry.session,
+			Expires: entry.expires,
+		})
+	}
+	c.mu.Unlock()
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	data, err = seal(c.aead, data)
+	if err != nil {
+		return err
+	}
+
+	// replace the snapshot atomically, so it is never read half written
+	tmp, err := os.CreateTemp(filepath.Dir(c.config.SnapshotFile), filepath.Base(c.config.SnapshotFile)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.config.SnapshotFile)
+}
+
+func (c *CacheClient) loadSnapshot() {
+	// a missing or invalid snapshot starts an empty cache
+	data, err := os.ReadFile(c.config.SnapshotFile)
+	if err != nil {
+		return
+	}
+
+	data, err = open(c.aead, data)
+	if err != nil {
+		return
+	}
+
+	var entries []*snapshotEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return
+	}
+
+	now := time.Now()
+	maxExpires := now.Add(c.config.Duration)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, e := range entries {
+		if e.Key == "" || e.Session == nil || !now.Before(e.Expires) {
+			// drop expired entries
+			continue
+		}
+
+		entry := &cacheEntry{
+			key:     e.Key,
+			users:   e.Session.GetUsers(),
+			session: e.Session,
+			size:    getEntrySize(e.Key, e.Session),
+			expires: e.Expires,
+		}
+
+		if entry.expires.After(maxExpires) {
+			// never keep entries longer than the configured duration
+			entry.expires = maxExpires
+		}
+
+		if c.config.MaxBytes > 0 && entry.size > c.config.MaxBytes {
+			continue
+		}
+
+		if el, ok := c.entries[e.Key]; ok {
+			c.remove(el)
+		}
+
+		c.add(entry)
+	}
+}
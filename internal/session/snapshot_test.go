@@ -0,0 +1,196 @@
+package session_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/session"
+)
+
+func newSnapshotConfig(path string) *session.Config {
+	return &session.Config{
+		Duration:      time.Minute,
+		SnapshotFile:  path,
+		EncryptionKey: "secret",
+	}
+}
+
+func TestCacheClient_Snapshot(t *testing.T) {
+	t.Run("restore sessions", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "snapshot")
+
+		client := session.NewCacheClient(context.Background(), newSnapshotConfig(path))
+		client.Set("first", &session.Session{
+			IsAuthenticated: true,
+			Headers:         http.Header{"X-Authentik-Username": []string{"jdoe"}},
+		}, time.Minute)
+		client.Set("expired", &session.Session{IsAuthenticated: true}, 10*time.Millisecond)
+
+		if err := client.WriteSnapshot(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		time.Sleep(20 * time.Millisecond)
+
+		restored := session.NewCacheClient(context.Background(), newSnapshotConfig(path))
+
+		s, _ := restored.Get("first")
+		if s == nil {
+			t.Fatal("expected session to be restored")
+		}
+
+		if s.Headers.Get("X-Authentik-Username") != "jdoe" {
+			t.Errorf("expected username header to be jdoe, got %s", s.Headers.Get("X-Authentik-Username"))
+		}
+
+		// check that expired sessions are dropped
+		if restored.Len() != 1 {
+			t.Errorf("expected 1 entry, got %d", restored.Len())
+		}
+
+		// check that the user index is restored
+		restored.DeleteUser(session.UserByUsername("jdoe"), "")
+		if restored.Len() != 0 {
+			t.Errorf("expected 0 entries, got %d", restored.Len())
+		}
+	})
+
+	t.Run("write on context cancellation", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "snapshot")
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		client := session.NewCacheClient(ctx, newSnapshotConfig(path))
+		client.Set("first", &session.Session{IsAuthenticated: true}, time.Minute)
+
+		cancel()
+
+		deadline := time.Now().Add(time.Second)
+		for {
+			if _, err := os.Stat(path); err == nil {
+				break
+			}
+
+			if time.Now().After(deadline) {
+				t.Fatal("expected snapshot to be written")
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		restored := session.NewCacheClient(context.Background(), newSnapshotConfig(path))
+		if s, _ := restored.Get("first"); s == nil {
+			t.Error("expected session to be restored")
+		}
+	})
+
+	t.Run("encrypt snapshot", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "snapshot")
+
+		client := session.NewCacheClient(context.Background(), newSnapshotConfig(path))
+		client.Set("first", &session.Session{
+			IsAuthenticated: true,
+			Headers:         http.Header{"X-Authentik-Username": []string{"jdoe"}},
+		}, time.Minute)
+
+		if err := client.WriteSnapshot(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if strings.Contains(string(data), "jdoe") {
+			t.Error("expected snapshot to be encrypted")
+		}
+
+		// check that a snapshot encrypted with another key is ignored
+		config := newSnapshotConfig(path)
+		config.EncryptionKey = "other"
+
+		restored := session.NewCacheClient(context.Background(), config)
+		if restored.Len() != 0 {
+			t.Errorf("expected 0 entries, got %d", restored.Len())
+		}
+	})
+
+	t.Run("restore within limits", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "snapshot")
+
+		client := session.NewCacheClient(context.Background(), newSnapshotConfig(path))
+		client.Set("first", &session.Session{IsAuthenticated: true}, time.Minute)
+		client.Set("second", &session.Session{IsAuthenticated: true}, time.Minute)
+
+		if err := client.WriteSnapshot(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		config := newSnapshotConfig(path)
+		config.MaxEntries = 1
+
+		restored := session.NewCacheClient(context.Background(), config)
+
+		// check that the most recently used session is kept
+		if s, _ := restored.Get("second"); s == nil {
+			t.Error("expected most recently used session to be restored")
+		}
+
+		if s, _ := restored.Get("first"); s != nil {
+			t.Error("expected least recently used session to be evicted")
+		}
+	})
+}
+
+func TestClaimSnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.snapshot")
+
+	firstCtx, firstCancel := context.WithCancel(context.Background())
+	defer firstCancel()
+
+	if err := session.ClaimSnapshotFile(firstCtx, path, "first"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	t.Run("with same owner", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		if err := session.ClaimSnapshotFile(ctx, path, "first"); err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("with another owner", func(t *testing.T) {
+		err := session.ClaimSnapshotFile(context.Background(), path, "second")
+		if !errors.Is(err, session.ErrSnapshotInUse) {
+			t.Errorf("expected snapshot in use error, got %v", err)
+		}
+	})
+
+	t.Run("after the owner is discarded", func(t *testing.T) {
+		firstCancel()
+
+		var err error
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if err = session.ClaimSnapshotFile(ctx, path, "second"); err == nil {
+				cancel()
+				break
+			}
+
+			cancel()
+		}
+
+		if err != nil {
+			t.Errorf("expected no error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,16 @@
+package tracing
+
+import (
+	"time"
+)
+
+type Config struct {
+	Endpoint    string
+	ServiceName string
+	Headers     map[string]string
+	Interval    time.Duration
+	Timeout     time.Duration
+
+	// fraction of requests without a valid traceparent that start a sampled trace
+	RootSampleRate float64
+}
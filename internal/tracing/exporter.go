@@ -0,0 +1,206 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	scopeName = "github.com/xabinapal/traefik-authentik-forward-plugin"
+
+	maxBatchSize = 512
+	maxQueueSize = 4096
+)
+
+// exporter sends finished spans in batches to an OTLP/HTTP collector, using
+// the JSON encoding of the protocol.
+type exporter struct {
+	config *Config
+	client *http.Client
+
+	mu    sync.Mutex
+	queue []*Span
+	flush chan struct{}
+}
+
+func newExporter(context context.Context, config *Config) *exporter {
+	e := &exporter{
+		config: config,
+		client: &http.Client{Timeout: config.Timeout},
+		flush:  make(chan struct{}, 1),
+	}
+
+	go e.run(context)
+
+	return e
+}
+
+func (e *exporter) Add(s *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.queue) >= maxQueueSize {
+		// drop spans while the collector is not keeping up
+		return
+	}
+
+	e.queue = append(e.queue, s)
+
+	if len(e.queue) >= maxBatchSize {
+		select {
+		case e.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *exporter) run(ctx context.Context) {
+	ticker := time.NewTicker(e.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.flush:
+		case <-ctx.Done():
+			// send the remaining spans when the plugin is discarded
+			e.export(context.WithoutCancel(ctx))
+			return
+		}
+
+		e.export(ctx)
+	}
+}
+
+func (e *exporter) export(ctx context.Context) {
+	for {
+		e.mu.Lock()
+		n := min(len(e.queue), maxBatchSize)
+		batch := e.queue[:n]
+		e.queue = e.queue[n:]
+		e.mu.Unlock()
+
+		if n == 0 {
+			return
+		}
+
+		// export failures are not retried, spans are best effort
+		_ = e.send(ctx, batch)
+	}
+}
+
+func (e *exporter) send(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(e.encode(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	return nil
+}
+
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []attribute `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope      `json:"scope"`
+	Spans []spanData `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type spanData struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	ParentSpanID      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []attribute `json:"attributes,omitempty"`
+	Status            spanStatus  `json:"status"`
+}
+
+type spanStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+func (e *exporter) encode(spans []*Span) *exportRequest {
+	serviceName := e.config.ServiceName
+
+	data := make([]spanData, 0, len(spans))
+	for _, s := range spans {
+		s.mu.Lock()
+
+		d := spanData{
+			TraceID:           hex.EncodeToString(s.context.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.context.SpanID[:]),
+			Name:              s.name,
+			Kind:              kindClient,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        s.attributes,
+			Status:            spanStatus{Code: s.status, Message: s.message},
+		}
+
+		if s.parent.IsValid() {
+			d.ParentSpanID = hex.EncodeToString(s.parent.SpanID[:])
+		}
+
+		s.mu.Unlock()
+
+		data = append(data, d)
+	}
+
+	return &exportRequest{
+		ResourceSpans: []resourceSpans{{
+			Resource: resource{
+				Attributes: []attribute{{
+					Key:   "service.name",
+					Value: attributeValue{StringValue: &serviceName},
+				}},
+			},
+			ScopeSpans: []scopeSpans{{
+				Scope: scope{Name: scopeName},
+				Spans: data,
+			}},
+		}},
+	}
+}
@@ -0,0 +1,97 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	TraceparentHeader = "Traceparent"
+	TracestateHeader  = "Tracestate"
+
+	flagSampled = 0x01
+)
+
+// SpanContext is the identity of a span, as propagated in the W3C traceparent header.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+// ParseTraceparent parses a W3C traceparent header value.
+func ParseTraceparent(s string) (SpanContext, bool) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, false
+	}
+
+	// version 00 has exactly four fields, future versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, false
+	}
+
+	if !decodeHex(sc.TraceID[:], parts[1]) || !decodeHex(sc.SpanID[:], parts[2]) {
+		return sc, false
+	}
+
+	var flags [1]byte
+	if !decodeHex(flags[:], parts[3]) {
+		return sc, false
+	}
+
+	sc.Flags = flags[0]
+
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+
+	return sc, true
+}
+
+// IsValid reports whether both the trace and span ids are non zero.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&flagSampled != 0
+}
+
+// Traceparent returns the span context as a W3C traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" +
+		hex.EncodeToString([]byte{sc.Flags})
+}
+
+// newChild returns a new span context within the trace of the parent, or
+// within a new trace, sampled only if requested, if the parent is not valid.
+func newChild(parent SpanContext, sampleRoot bool) SpanContext {
+	sc := SpanContext{TraceID: parent.TraceID, Flags: parent.Flags}
+
+	if !parent.IsValid() {
+		_, _ = rand.Read(sc.TraceID[:])
+
+		sc.Flags = 0
+		if sampleRoot {
+			sc.Flags = flagSampled
+		}
+	}
+
+	_, _ = rand.Read(sc.SpanID[:])
+
+	return sc
+}
+
+func decodeHex(dst []byte, s string) bool {
+	// only lowercase hex is allowed by the specification
+	if len(s) != hex.EncodedLen(len(dst)) || strings.ToLower(s) != s {
+		return false
+	}
+
+	_, err := hex.Decode(dst, []byte(s))
+	return err == nil
+}
@@ -0,0 +1,57 @@
+package tracing_test
+
+import (
+	"testing"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/tracing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		valid   bool
+		sampled bool
+	}{
+		{"with sampled trace", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"with unsampled trace", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"with future version", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, true},
+		{"with empty value", "", false, false},
+		{"with invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"with extra fields", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"with zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"with zero span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"with uppercase hex", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"with short trace id", "00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := tracing.ParseTraceparent(tt.value)
+			if ok != tt.valid {
+				t.Fatalf("expected valid %t, got %t", tt.valid, ok)
+			}
+
+			if !ok {
+				return
+			}
+
+			if sc.IsSampled() != tt.sampled {
+				t.Errorf("expected sampled %t, got %t", tt.sampled, sc.IsSampled())
+			}
+		})
+	}
+
+	t.Run("with round trip", func(t *testing.T) {
+		value := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+		sc, ok := tracing.ParseTraceparent(value)
+		if !ok {
+			t.Fatal("expected traceparent to be valid")
+		}
+
+		if sc.Traceparent() != value {
+			t.Errorf("expected traceparent %s, got %s", value, sc.Traceparent())
+		}
+	})
+}
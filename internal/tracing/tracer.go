@@ -0,0 +1,140 @@
+package tracing
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// status code of failed spans
+	statusError = 2
+
+	// span kind of outbound requests
+	kindClient = 3
+)
+
+type Tracer struct {
+	config   *Config
+	exporter *exporter
+}
+
+func New(context context.Context, config *Config) *Tracer {
+	return &Tracer{
+		config:   config,
+		exporter: newExporter(context, config),
+	}
+}
+
+// Start starts a span as a child of the traceparent header value, or as the
+// root of a new trace if the value is empty or invalid. Root spans are only
+// sampled at the configured root sample rate.
+func (t *Tracer) Start(name string, traceparent string) *Span {
+	if t == nil {
+		return nil
+	}
+
+	parent, ok := ParseTraceparent(traceparent)
+	sampleRoot := !ok && t.config.RootSampleRate > 0 && rand.Float64() < t.config.RootSampleRate //nolint:gosec
+
+	return &Span{
+		tracer:  t,
+		name:    name,
+		parent:  parent,
+		context: newChild(parent, sampleRoot),
+		start:   time.Now(),
+	}
+}
+
+type Span struct {
+	tracer  *Tracer
+	name    string
+	parent  SpanContext
+	context SpanContext
+	start   time.Time
+
+	mu         sync.Mutex
+	end        time.Time
+	attributes []attribute
+	status     int
+	message    string
+}
+
+type attribute struct {
+	Key   string         `json:"key"`
+	Value attributeValue `json:"value"`
+}
+
+type attributeValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+// Traceparent returns the traceparent header value to propagate the span.
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+
+	return s.context.Traceparent()
+}
+
+func (s *Span) SetString(key string, value string) {
+	s.setAttribute(key, attributeValue{StringValue: &value})
+}
+
+func (s *Span) SetInt(key string, value int) {
+	v := strconv.Itoa(value)
+	s.setAttribute(key, attributeValue{IntValue: &v})
+}
+
+func (s *Span) SetBool(key string, value bool) {
+	s.setAttribute(key, attributeValue{BoolValue: &value})
+}
+
+func (s *Span) setAttribute(key string, value attributeValue) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attributes = append(s.attributes, attribute{Key: key, Value: value})
+}
+
+// SetError marks the span as failed.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status = statusError
+	s.message = err.Error()
+}
+
+// End ends the span and queues it for export if its trace is sampled.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if !s.end.IsZero() {
+		s.mu.Unlock()
+		return
+	}
+
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if s.context.IsSampled() {
+		s.tracer.exporter.Add(s)
+	}
+}
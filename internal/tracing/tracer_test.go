@@ -0,0 +1,255 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/tracing"
+)
+
+type collectedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Kind         int    `json:"kind"`
+	Attributes   []struct {
+		Key   string `json:"key"`
+		Value struct {
+			StringValue string `json:"stringValue"`
+			IntValue    string `json:"intValue"`
+		} `json:"value"`
+	} `json:"attributes"`
+	Status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+}
+
+// newCollector returns a collector server that sends every exported span to
+// the returned channel.
+func newCollector(t *testing.T) (*httptest.Server, <-chan collectedSpan) {
+	t.Helper()
+
+	spans := make(chan collectedSpan, 16)
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("expected json content type, got %s", req.Header.Get("Content-Type"))
+		}
+
+		if req.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("expected authorization header, got %s", req.Header.Get("Authorization"))
+		}
+
+		body, _ := io.ReadAll(req.Body)
+
+		var payload struct {
+			ResourceSpans []struct {
+				Resource struct {
+					Attributes []struct {
+						Key   string `json:"key"`
+						Value struct {
+							StringValue string `json:"stringValue"`
+						} `json:"value"`
+					} `json:"attributes"`
+				} `json:"resource"`
+				ScopeSpans []struct {
+					Spans []collectedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+
+		for _, rs := range payload.ResourceSpans {
+			if rs.Resource.Attributes[0].Value.StringValue != "test-service" {
+				t.Errorf("expected service name test-service, got %s", rs.Resource.Attributes[0].Value.StringValue)
+			}
+
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					spans <- s
+				}
+			}
+		}
+	}))
+
+	return server, spans
+}
+
+func receive(t *testing.T, spans <-chan collectedSpan) collectedSpan {
+	t.Helper()
+
+	select {
+	case s := <-spans:
+		return s
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected span to be exported")
+		return collectedSpan{}
+	}
+}
+
+func TestTracer(t *testing.T) {
+	server, spans := newCollector(t)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tracer := tracing.New(ctx, &tracing.Config{
+		Endpoint:    server.URL + "/v1/traces",
+		ServiceName: "test-service",
+		Headers:     map[string]string{"Authorization": "Bearer token"},
+		Interval:    10 * time.Millisecond,
+		Timeout:     time.Second,
+	})
+
+	t.Run("with sampled parent", func(t *testing.T) {
+		parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+		span := tracer.Start("authentik check", parent)
+		span.SetString("url.path", "/outpost.goauthentik.io/auth/nginx")
+		span.SetInt("http.response.status_code", 200)
+		span.End()
+
+		// check that the propagated context belongs to the parent trace
+		traceparent := span.Traceparent()
+		if !strings.HasPrefix(traceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || strings.Contains(traceparent, "00f067aa0ba902b7") {
+			t.Errorf("expected child traceparent of the parent trace, got %s", traceparent)
+		}
+
+		s := receive(t, spans)
+
+		if s.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("expected trace id of the parent, got %s", s.TraceID)
+		}
+
+		if s.ParentSpanID != "00f067aa0ba902b7" {
+			t.Errorf("expected parent span id 00f067aa0ba902b7, got %s", s.ParentSpanID)
+		}
+
+		if !strings.Contains(traceparent, s.SpanID) {
+			t.Errorf("expected span id %s to be propagated, got %s", s.SpanID, traceparent)
+		}
+
+		if s.Name != "authentik check" {
+			t.Errorf("expected span name authentik check, got %s", s.Name)
+		}
+
+		if len(s.Attributes) != 2 || s.Attributes[1].Value.IntValue != "200" {
+			t.Errorf("expected status code attribute, got %+v", s.Attributes)
+		}
+	})
+
+	t.Run("with failed span", func(t *testing.T) {
+		parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+		span := tracer.Start("authentik outpost", parent)
+		span.SetError(errors.New("connection refused"))
+		span.End()
+
+		s := receive(t, spans)
+
+		if s.Status.Code != 2 || s.Status.Message != "connection refused" {
+			t.Errorf("expected error status, got %+v", s.Status)
+		}
+	})
+
+	t.Run("with unsampled parent", func(t *testing.T) {
+		parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"
+
+		span := tracer.Start("authentik check", parent)
+		span.End()
+
+		if !strings.HasSuffix(span.Traceparent(), "-00") {
+			t.Errorf("expected unsampled traceparent, got %s", span.Traceparent())
+		}
+
+		select {
+		case s := <-spans:
+			t.Errorf("expected unsampled span not to be exported, got %+v", s)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("without parent", func(t *testing.T) {
+		span := tracer.Start("authentik check", "")
+		span.End()
+
+		// check that root spans are not sampled by default
+		if !strings.HasSuffix(span.Traceparent(), "-00") {
+			t.Errorf("expected unsampled traceparent, got %s", span.Traceparent())
+		}
+
+		select {
+		case s := <-spans:
+			t.Errorf("expected root span not to be exported, got %+v", s)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("without parent and root sampling", func(t *testing.T) {
+		tracer := tracing.New(ctx, &tracing.Config{
+			Endpoint:       server.URL + "/v1/traces",
+			ServiceName:    "test-service",
+			Headers:        map[string]string{"Authorization": "Bearer token"},
+			Interval:       10 * time.Millisecond,
+			Timeout:        time.Second,
+			RootSampleRate: 1,
+		})
+
+		span := tracer.Start("authentik check", "")
+		span.End()
+
+		s := receive(t, spans)
+
+		if s.ParentSpanID != "" {
+			t.Errorf("expected root span, got parent %s", s.ParentSpanID)
+		}
+	})
+
+	t.Run("with nil tracer", func(t *testing.T) {
+		var tracer *tracing.Tracer
+
+		span := tracer.Start("authentik check", "")
+		span.SetString("url.path", "/")
+		span.End()
+
+		if span.Traceparent() != "" {
+			t.Errorf("expected empty traceparent, got %s", span.Traceparent())
+		}
+	})
+}
+
+func TestTracer_Shutdown(t *testing.T) {
+	server, spans := newCollector(t)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	tracer := tracing.New(ctx, &tracing.Config{
+		Endpoint:    server.URL + "/v1/traces",
+		ServiceName: "test-service",
+		Headers:     map[string]string{"Authorization": "Bearer token"},
+		Interval:    time.Hour,
+		Timeout:     time.Second,
+	})
+
+	span := tracer.Start("authentik check", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	span.End()
+
+	// check that queued spans are exported when the plugin is discarded
+	cancel()
+	receive(t, spans)
+}
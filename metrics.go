@@ -0,0 +1,113 @@
+package traefik_authentik_forward_plugin
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/authentik"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/metrics"
+)
+
+const (
+	DecisionSkipped      = "skipped"
+	DecisionPreflight    = "preflight"
+	DecisionAllowed      = "allowed"
+	DecisionAnonymous    = "anonymous"
+	DecisionUnauthorized = "unauthorized"
+	DecisionRedirected   = "redirected"
+	DecisionDenied       = "denied"
+	DecisionForbidden    = "forbidden"
+	DecisionError        = "error"
+	DecisionProxied      = "proxied"
+)
+
+type pluginMetrics struct {
+	registry  *metrics.Registry
+	decisions *metrics.CounterVec
+	outpost   *metrics.CounterVec
+}
+
+func newPluginMetrics(client *authentik.Client) *pluginMetrics {
+	m := &pluginMetrics{
+		registry: metrics.NewRegistry(),
+		decisions: metrics.NewCounterVec(
+			"traefik_authentik_decisions_total",
+			"Decisions taken on upstream requests by matching rule.",
+			"rule", "decision",
+		),
+		outpost: metrics.NewCounterVec(
+			"traefik_authentik_outpost_requests_total",
+			"Requests proxied to the authentik outpost by response status.",
+			"status",
+		),
+	}
+
+	m.registry.Register(client.Collectors()...)
+	m.registry.Register(m.decisions, m.outpost)
+
+	return m
+}
+
+func (m *pluginMetrics) Decide(rule string, decision string) {
+	if rule == "" {
+		rule = "default"
+	}
+
+	m.decisions.Inc(rule, decision)
+}
+
+func (p *Plugin) handleMetrics(req *http.Request, rw http.ResponseWriter) {
+	if req.Method != http.MethodGet {
+		rw.Header().Set("Allow", http.MethodGet)
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !p.isMetricsAuthorized(req) {
+		http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	rw.WriteHeader(http.StatusOK)
+
+	_ = p.metrics.registry.Write(rw)
+}
+
+func (p *Plugin) isMetricsAuthorized(req *http.Request) bool {
+	cfg := p.config.Metrics
+
+	// every configured restriction must be satisfied
+	if len(cfg.AllowedIPs) > 0 {
+		host, _, err := net.SplitHostPort(req.RemoteAddr)
+		if err != nil {
+			host = req.RemoteAddr
+		}
+
+		ip := net.ParseIP(host)
+		if ip == nil || !containsIP(cfg.AllowedIPs, ip) {
+			return false
+		}
+	}
+
+	if cfg.Token != "" {
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.Token)) != 1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -2,30 +2,107 @@ package traefik_authentik_forward_plugin
 
 import (
 	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/audit"
 	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/authentik"
 	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/config"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/denylist"
 	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/httpclient"
 	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/httputil"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/session"
+	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/tracing"
 )
 
+const maxWebhookBodySize = 1 << 20
+
 func CreateConfig() *config.Config {
 	return &config.Config{
 		// authentik settings
-		Address:       "",
-		CacheDuration: config.DefaultCacheDuration,
+		Address:               "",
+		Addresses:             []string{},
+		AddressStrategy:       config.DefaultAddressStrategy,
+		AddressCooldown:       config.DefaultAddressCooldown,
+		CacheDuration:         config.DefaultCacheDuration,
+		CacheNegativeDuration: "",
+		CacheRevalidateWindow: config.DefaultCacheRevalidateWindow,
+		CacheStaleIfError:     config.DefaultCacheStaleIfError,
+		CacheRespectHeaders:   config.DefaultCacheRespectHeaders,
+		CacheMaxEntries:       config.DefaultCacheMaxEntries,
+		CacheMaxBytes:         config.DefaultCacheMaxBytes,
+		CacheShared:           config.DefaultCacheShared,
+		CacheSignOutScope:     config.DefaultCacheSignOutScope,
+		CacheSnapshot: config.CacheSnapshotConfig{
+			File:          "",
+			Interval:      config.DefaultCacheSnapshotInterval,
+			EncryptionKey: "",
+		},
+		CacheBackend: config.DefaultCacheBackend,
+		CacheRedis: config.CacheRedisConfig{
+			Address:       "",
+			Password:      "",
+			Database:      0,
+			Prefix:        config.DefaultCacheRedisPrefix,
+			EncryptionKey: "",
+			Timeout:       config.DefaultCacheRedisTimeout,
+		},
+
+		CircuitBreakerThreshold: config.DefaultCircuitBreakerThreshold,
+		CircuitBreakerCooldown:  config.DefaultCircuitBreakerCooldown,
+
+		RetryAttempts:   config.DefaultRetryAttempts,
+		RetryBackoff:    config.DefaultRetryBackoff,
+		RetryMaxBackoff: config.DefaultRetryMaxBackoff,
+
+		WebhookPath:   "",
+		WebhookSecret: "",
+
+		Tracing: config.TracingConfig{
+			Endpoint:       "",
+			ServiceName:    config.DefaultTracingServiceName,
+			Headers:        map[string]string{},
+			Interval:       config.DefaultTracingInterval,
+			Timeout:        config.DefaultTracingTimeout,
+			RootSampleRate: config.DefaultTracingRootSampleRate,
+		},
 
 		UnauthorizedStatusCode: config.DefaultUnauthorizedStatusCode,
 		RedirectStatusCode:     config.DefaultRedirectStatusCode,
+		ForbiddenStatusCode:    config.DefaultForbiddenStatusCode,
 
+		Rules:             []config.RuleConfig{},
 		SkippedPaths:      config.DefaultSkippedPaths,
 		UnauthorizedPaths: config.DefaultUnauthorizedPaths,
 		RedirectPaths:     config.DefaultRedirectPaths,
+		GroupPaths:        []config.GroupPathConfig{},
+
+		CORSPreflight:      config.DefaultCORSPreflight,
+		CORSAllowedOrigins: []string{},
+
+		// metrics settings
+		MetricsPath:       "",
+		MetricsAllowedIPs: []string{},
+		MetricsToken:      "",
+
+		// audit log settings
+		AuditLog: config.AuditLogConfig{
+			Output:     "",
+			SampleRate: config.DefaultAuditLogSampleRate,
+			Redact:     []string{},
+		},
+
+		// denylist settings
+		DenylistFile:     "",
+		DenylistInterval: config.DefaultDenylistInterval,
 
 		// http settings
 		Timeout: config.DefaultTimeout,
@@ -41,10 +118,13 @@ func CreateConfig() *config.Config {
 }
 
 type Plugin struct {
-	name   string
-	next   http.Handler
-	config *config.PluginConfig
-	client *authentik.Client
+	name     string
+	next     http.Handler
+	config   *config.PluginConfig
+	client   *authentik.Client
+	denylist *denylist.Denylist
+	metrics  *pluginMetrics
+	audit    *audit.Logger
 }
 
 func New(ctx context.Context, next http.Handler, config *config.Config, name string) (http.Handler, error) {
@@ -58,17 +138,54 @@ func New(ctx context.Context, next http.Handler, config *config.Config, name str
 		return nil, fmt.Errorf("failed to create http client: %w", err)
 	}
 
+	var dl *denylist.Denylist
+	if pc.Denylist != nil {
+		dl, err = denylist.New(ctx, pc.Denylist)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create denylist: %w", err)
+		}
+	}
+
+	var al *audit.Logger
+	if pc.AuditLog != nil {
+		al, err = audit.New(ctx, pc.AuditLog)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create audit logger: %w", err)
+		}
+	}
+
+	if pc.Authentik.CacheSnapshotFile != "" {
+		if err := authentik.ClaimSnapshotFile(ctx, pc.Authentik, name); err != nil {
+			return nil, fmt.Errorf("failed to claim cache snapshot: %w", err)
+		}
+	}
+
 	client := authentik.NewClient(ctx, httpClient, pc.Authentik)
 
 	return &Plugin{
-		name:   name,
-		next:   next,
-		config: pc,
-		client: client,
+		name:     name,
+		next:     next,
+		config:   pc,
+		client:   client,
+		denylist: dl,
+		metrics:  newPluginMetrics(client),
+		audit:    al,
 	}, nil
 }
 
 func (p *Plugin) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if p.config.Metrics != nil && req.URL.Path == p.config.Metrics.Path {
+		// serve plugin metrics
+		p.handleMetrics(req, rw)
+		return
+	}
+
+	if p.config.Authentik.WebhookPath != "" && req.URL.Path == p.config.Authentik.WebhookPath {
+		// handle authentik notifications
+		p.handleWebhook(req, rw)
+		return
+	}
+
 	meta, err := p.handleRequest(req)
 	if err != nil {
 		http.Error(rw, err.Error(), http.StatusInternalServerError)
@@ -91,8 +208,12 @@ func (p *Plugin) handleRequest(req *http.Request) (*authentik.RequestMeta, error
 	}
 
 	meta := &authentik.RequestMeta{
+		Context: req.Context(),
 		URL:     url,
 		Cookies: authentik.GetCookies(req),
+
+		Traceparent: req.Header.Get(tracing.TraceparentHeader),
+		Tracestate:  strings.Join(req.Header.Values(tracing.TracestateHeader), ","),
 	}
 
 	// remove authentik headers and cookies in request to upstream
@@ -104,6 +225,7 @@ func (p *Plugin) handleRequest(req *http.Request) (*authentik.RequestMeta, error
 func (p *Plugin) handleAuthentik(meta *authentik.RequestMeta, req *http.Request, rw http.ResponseWriter) {
 	if req.Method != http.MethodGet {
 		// only allow get requests to authentik
+		p.logAudit(req, meta, "", DecisionDenied, false, nil)
 		rw.WriteHeader(http.StatusMethodNotAllowed)
 		_, _ = rw.Write([]byte(http.StatusText(http.StatusMethodNotAllowed)))
 		return
@@ -111,6 +233,7 @@ func (p *Plugin) handleAuthentik(meta *authentik.RequestMeta, req *http.Request,
 
 	if !authentik.IsAuthentikPathAllowed(meta.URL.Path) {
 		// return not found for internal authentik paths
+		p.logAudit(req, meta, "", DecisionDenied, false, nil)
 		rw.WriteHeader(http.StatusNotFound)
 		_, _ = rw.Write([]byte(http.StatusText(http.StatusNotFound)))
 		return
@@ -119,11 +242,16 @@ func (p *Plugin) handleAuthentik(meta *authentik.RequestMeta, req *http.Request,
 	// send request to authentik
 	res, err := p.client.Request(meta, meta.URL.Path, meta.URL.RawQuery)
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		p.metrics.outpost.Inc("error")
+		p.logAudit(req, meta, "", DecisionError, false, nil)
+		p.serveError(err, rw)
 		return
 	}
 	defer func() { _ = res.Body.Close() }()
 
+	p.metrics.outpost.Inc(strconv.Itoa(res.StatusCode))
+	p.logAudit(req, meta, "", DecisionProxied, false, nil)
+
 	// write authentik response to downstream
 	for k, vs := range res.Header {
 		if strings.HasPrefix(k, authentik.HeaderPrefix) {
@@ -144,8 +272,26 @@ func (p *Plugin) handleAuthentik(meta *authentik.RequestMeta, req *http.Request,
 }
 
 func (p *Plugin) handleUpstream(meta *authentik.RequestMeta, req *http.Request, rw http.ResponseWriter) {
-	if p.config.Authentik.IsSkippedPath(meta.URL.Path) {
+	if httputil.IsCORSPreflight(req) && p.config.Authentik.IsPreflightBypassed(req.Header.Get("Origin")) {
+		// send preflight requests to upstream, as browsers never include credentials
+		p.decide(req, meta, "", DecisionPreflight, false, nil)
+		p.serveUpstream(nil, req, rw)
+		return
+	}
+
+	// get the first rule matching the request
+	matched := p.config.Authentik.GetRule(meta.URL, req)
+	rule, sc := matched.Name, matched.StatusCode
+
+	negotiate := matched.Action == authentik.ActionNegotiate
+	if negotiate && httputil.IsNavigation(req) {
+		// redirect browsers navigating to the page
+		sc = p.config.Authentik.RedirectStatusCode
+	}
+
+	if matched.Action == authentik.ActionSkip {
 		// send request to upstream without checking for authentication
+		p.decide(req, meta, rule, DecisionSkipped, false, nil)
 		p.serveUpstream(nil, req, rw)
 		return
 	}
@@ -153,22 +299,90 @@ func (p *Plugin) handleUpstream(meta *authentik.RequestMeta, req *http.Request,
 	// check if request is authenticated in authentik
 	resMeta, err := p.client.Check(meta)
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		p.decide(req, meta, rule, DecisionError, false, nil)
+		p.serveError(err, rw)
 		return
 	}
 
-	// get status code to return if request is not authenticated
-	sc := p.config.Authentik.GetUnauthorizedStatusCode(meta.URL.Path)
+	// keep the checked session to record the user of denied requests
+	checked := resMeta.Session
 
-	if !resMeta.Session.IsAuthenticated && sc != http.StatusOK {
+	denied := p.denylist != nil && p.denylist.Match(resMeta.Session)
+	if denied {
+		// treat denied users as unauthenticated and evict their sessions
+		p.client.Revoke(meta, resMeta.Session)
+		resMeta.Session = &session.Session{IsAuthenticated: false}
+
+		if sc >= 300 && sc < 400 {
+			// authentik would redirect the still signed in user back
+			sc = p.config.Authentik.UnauthorizedStatusCode
+			negotiate = false
+		}
+	}
+
+	switch {
+	case !resMeta.Session.IsAuthenticated && sc != http.StatusOK:
 		// return unauthorized if request is not authenticated and path is not allowed
-		p.serveUnauthorized(resMeta, rw, sc)
-	} else {
+		p.decide(req, meta, rule, getUnauthorizedDecision(sc, denied), resMeta.Cached, checked)
+		p.serveUnauthorized(resMeta, rw, sc, negotiate)
+	case !resMeta.Session.IsAuthenticated:
+		// send request to upstream without authentication metadata
+		p.decide(req, meta, rule, getUnauthorizedDecision(sc, denied), resMeta.Cached, checked)
+		p.serveUpstream(resMeta, req, rw)
+	case !matched.IsAuthorized(resMeta.Session.GetGroups()):
+		// return forbidden if user is not in the groups required by the rule
+		p.decide(req, meta, rule, DecisionForbidden, resMeta.Cached, checked)
+		p.serveUnauthorized(resMeta, rw, p.config.Authentik.ForbiddenStatusCode, false)
+	default:
 		// send request to upstream with authentication metadata
+		p.decide(req, meta, rule, DecisionAllowed, resMeta.Cached, checked)
 		p.serveUpstream(resMeta, req, rw)
 	}
 }
 
+// decide records the decision taken on an upstream request.
+func (p *Plugin) decide(req *http.Request, meta *authentik.RequestMeta, rule string, decision string, cached bool, s *session.Session) {
+	p.metrics.Decide(rule, decision)
+	p.logAudit(req, meta, rule, decision, cached, s)
+}
+
+// logAudit writes the decision taken on the request to the audit log, if enabled.
+func (p *Plugin) logAudit(req *http.Request, meta *authentik.RequestMeta, rule string, decision string, cached bool, s *session.Session) {
+	if p.audit == nil {
+		return
+	}
+
+	entry := &audit.Entry{
+		Time:     time.Now().UTC(),
+		Host:     meta.URL.Host,
+		Path:     meta.URL.Path,
+		Method:   req.Method,
+		Rule:     rule,
+		Decision: decision,
+		Cached:   cached,
+	}
+
+	if s != nil {
+		entry.Username = s.GetUsername()
+		entry.UID = s.GetUID()
+	}
+
+	p.audit.Log(entry)
+}
+
+func getUnauthorizedDecision(sc int, denied bool) string {
+	switch {
+	case denied:
+		return DecisionDenied
+	case sc == http.StatusOK:
+		return DecisionAnonymous
+	case sc >= 300 && sc < 400:
+		return DecisionRedirected
+	default:
+		return DecisionUnauthorized
+	}
+}
+
 func (p *Plugin) serveUpstream(meta *authentik.ResponseMeta, req *http.Request, rw http.ResponseWriter) {
 	var cookies []*http.Cookie
 
@@ -183,6 +397,14 @@ func (p *Plugin) serveUpstream(meta *authentik.ResponseMeta, req *http.Request,
 		// add cached header to upstream request
 		req.Header.Add(authentik.CachedHeaderKey, strconv.FormatBool(meta.Cached))
 
+		// add stale header to upstream request
+		req.Header.Add(authentik.StaleHeaderKey, strconv.FormatBool(meta.Stale))
+
+		if p.config.Authentik.BreakerThreshold > 0 {
+			// add circuit breaker state header to upstream request
+			req.Header.Add(authentik.BreakerHeaderKey, p.client.BreakerState().String())
+		}
+
 		cookies = meta.Session.Cookies
 	} else {
 		cookies = []*http.Cookie{}
@@ -197,18 +419,117 @@ func (p *Plugin) serveUpstream(meta *authentik.ResponseMeta, req *http.Request,
 	p.next.ServeHTTP(rcm, req)
 }
 
-func (p *Plugin) serveUnauthorized(meta *authentik.ResponseMeta, rw http.ResponseWriter, sc int) {
-	if sc >= 300 && sc < 400 {
+func (p *Plugin) serveUnauthorized(meta *authentik.ResponseMeta, rw http.ResponseWriter, sc int, negotiate bool) {
+	redirect := sc >= 300 && sc < 400
+
+	if redirect {
 		// redirect client to authentication flow start
 		loc := authentik.GetStartURL(meta.URL)
 		rw.Header().Set("Location", loc)
 	}
 
+	if negotiate {
+		// the response depends on the kind of client
+		for _, h := range httputil.NegotiationHeaders {
+			rw.Header().Add("Vary", h)
+		}
+	}
+
 	// add authentik session cookies to downstream response
 	for _, c := range meta.Session.Cookies {
 		rw.Header().Add("Set-Cookie", c.String())
 	}
 
+	if negotiate && !redirect {
+		// let scripted clients start the authentication flow themselves
+		p.serveLoginURL(meta, rw, sc)
+		return
+	}
+
 	rw.WriteHeader(sc)
 	_, _ = rw.Write([]byte(http.StatusText(sc)))
 }
+
+func (p *Plugin) serveLoginURL(meta *authentik.ResponseMeta, rw http.ResponseWriter, sc int) {
+	loc := authentik.GetStartURL(meta.URL)
+
+	body, err := json.Marshal(map[string]string{
+		"error":    http.StatusText(sc),
+		"loginUrl": loc,
+	})
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set(authentik.LoginURLHeaderKey, loc)
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(sc)
+	_, _ = rw.Write(body)
+}
+
+func (p *Plugin) handleWebhook(req *http.Request, rw http.ResponseWriter) {
+	if req.Method != http.MethodPost {
+		// notifications are only sent as post requests
+		rw.Header().Set("Allow", http.MethodPost)
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !p.isWebhookAuthorized(req) {
+		http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxWebhookBodySize))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	users, err := authentik.ParseNotification(body)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// drop cached sessions of the notified users
+	p.client.DeleteUsers(users)
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (p *Plugin) isWebhookAuthorized(req *http.Request) bool {
+	expected := p.config.Authentik.WebhookSecret
+	if expected == "" {
+		return false
+	}
+
+	// the secret is sent either as a bearer token or in the webhook url
+	secret := req.URL.Query().Get("secret")
+	if header := req.Header.Get("Authorization"); header != "" {
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			return false
+		}
+
+		secret = token
+	}
+
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(expected)) == 1
+}
+
+func (p *Plugin) serveError(err error, rw http.ResponseWriter) {
+	var circuitErr *authentik.CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		// fail fast and tell downstream when authentik will be probed again
+		retryAfter := int(math.Ceil(circuitErr.RetryAfter.Seconds()))
+		rw.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		rw.Header().Set(authentik.BreakerHeaderKey, p.client.BreakerState().String())
+
+		http.Error(rw, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	http.Error(rw, err.Error(), http.StatusInternalServerError)
+}
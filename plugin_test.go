@@ -2,9 +2,14 @@ package traefik_authentik_forward_plugin_test
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	plugin "github.com/xabinapal/traefik-authentik-forward-plugin"
 	"github.com/xabinapal/traefik-authentik-forward-plugin/internal/config"
@@ -403,3 +408,942 @@ func TestServeHTTP_AuthentikPaths(t *testing.T) {
 		}
 	})
 }
+
+func TestServeHTTP_CircuitBreaker(t *testing.T) {
+	t.Run("request with open circuit breaker", func(t *testing.T) {
+		akCalls := 0
+		akServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			akCalls++
+
+			rw.WriteHeader(http.StatusBadGateway)
+		}))
+		defer akServer.Close()
+
+		next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			// check that the next handler was not called
+			t.Fatalf("expected next handler not to be called")
+		})
+
+		config := &config.Config{
+			Address:                 akServer.URL,
+			CircuitBreakerThreshold: 1,
+			CircuitBreakerCooldown:  "1m",
+		}
+		handler, _ := plugin.New(context.Background(), next, config, "test")
+
+		// open the circuit breaker
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/users", nil)
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+
+		expectedCode := http.StatusInternalServerError
+		if rw.Code != expectedCode {
+			t.Errorf("expected status %d, got %d", expectedCode, rw.Code)
+		}
+
+		req = httptest.NewRequest(http.MethodGet, "http://example.com/users", nil)
+		rw = httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+
+		// check that the authentik server was called once
+		if akCalls != 1 {
+			t.Errorf("expected 1 authentik call, got %d", akCalls)
+		}
+
+		// check that the response fails fast
+		expectedCode = http.StatusServiceUnavailable
+		if rw.Code != expectedCode {
+			t.Errorf("expected status %d, got %d", expectedCode, rw.Code)
+		}
+
+		// check that the retry after header is set
+		expectedRetryAfter := "60"
+		if rw.Header().Get("Retry-After") != expectedRetryAfter {
+			t.Errorf("expected Retry-After header to be %s, got %s", expectedRetryAfter, rw.Header().Get("Retry-After"))
+		}
+
+		// check that the breaker state header is set
+		expectedState := "open"
+		if rw.Header().Get("X-Authentik-Traefik-Breaker") != expectedState {
+			t.Errorf("expected breaker header to be %s, got %s", expectedState, rw.Header().Get("X-Authentik-Traefik-Breaker"))
+		}
+	})
+
+	t.Run("request with closed circuit breaker", func(t *testing.T) {
+		akServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusOK)
+		}))
+		defer akServer.Close()
+
+		var breakerHeader string
+		next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			breakerHeader = req.Header.Get("X-Authentik-Traefik-Breaker")
+			rw.WriteHeader(http.StatusOK)
+		})
+
+		config := &config.Config{
+			Address:                 akServer.URL,
+			CircuitBreakerThreshold: 1,
+			CircuitBreakerCooldown:  "1m",
+		}
+		handler, _ := plugin.New(context.Background(), next, config, "test")
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/users", nil)
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+
+		// check that the breaker state is sent upstream
+		expectedState := "closed"
+		if breakerHeader != expectedState {
+			t.Errorf("expected breaker header to be %s, got %s", expectedState, breakerHeader)
+		}
+	})
+}
+
+func TestServeHTTP_Webhook(t *testing.T) {
+	newHandler := func(t *testing.T, akCalls *int) http.Handler {
+		t.Helper()
+
+		return newTestHandler(t, func(rw http.ResponseWriter, req *http.Request) {
+			*akCalls++
+
+			rw.Header().Set("X-Authentik-Uid", "5f8e1b6c")
+			rw.Header().Set("X-Authentik-Username", "jdoe")
+			rw.WriteHeader(http.StatusOK)
+		}, &config.Config{
+			CacheDuration: "1m",
+			WebhookPath:   "/_authentik/webhook",
+			WebhookSecret: "secret",
+		})
+	}
+
+	serveUpstream := func(handler http.Handler) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/users", nil)
+		req.AddCookie(&http.Cookie{Name: "authentik_proxy_session", Value: "test-session"})
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	tests := []struct {
+		name          string
+		target        string
+		authorization string
+		body          string
+		expectedCode  int
+		expectedCalls int
+	}{
+		{
+			name:          "with user payload",
+			target:        "http://example.com/_authentik/webhook?secret=secret",
+			body:          `{"action": "model_updated", "user": {"pk": 1, "username": "akadmin"}, "context": {"model": {"app": "authentik_core", "model_name": "user", "pk": 5, "name": "jdoe"}}}`,
+			expectedCode:  http.StatusNoContent,
+			expectedCalls: 2,
+		},
+		{
+			name:          "with event payload",
+			target:        "http://example.com/_authentik/webhook",
+			authorization: "Bearer secret",
+			body:          `{"event": {"action": "model_updated", "user": {"pk": 1, "username": "akadmin"}, "context": {"model": {"app": "authentik_core", "model_name": "user", "pk": 5, "name": "jdoe"}}}}`,
+			expectedCode:  http.StatusNoContent,
+			expectedCalls: 2,
+		},
+		{
+			name:          "with session payload",
+			target:        "http://example.com/_authentik/webhook?secret=secret",
+			body:          `{"event": {"action": "model_deleted", "user": {"pk": 1, "username": "akadmin"}, "context": {"model": {"app": "authentik_core", "model_name": "authenticatedsession", "pk": 9, "name": "Authenticated Session", "user": {"uid": "5f8e1b6c", "username": "jdoe"}}}}}`,
+			expectedCode:  http.StatusNoContent,
+			expectedCalls: 2,
+		},
+		{
+			name:          "with payload acted by user",
+			target:        "http://example.com/_authentik/webhook?secret=secret",
+			body:          `{"event": {"action": "model_updated", "user": {"pk": 5, "username": "jdoe"}, "context": {"model": {"app": "authentik_core", "model_name": "user", "pk": 6, "name": "other"}}}}`,
+			expectedCode:  http.StatusNoContent,
+			expectedCalls: 1,
+		},
+		{
+			name:          "with default payload",
+			target:        "http://example.com/_authentik/webhook?secret=secret",
+			body:          `{"body": "logout", "severity": "notice", "user_username": "akadmin", "event_user_username": "jdoe"}`,
+			expectedCode:  http.StatusNoContent,
+			expectedCalls: 2,
+		},
+		{
+			name:          "with default payload of other user",
+			target:        "http://example.com/_authentik/webhook?secret=secret",
+			body:          `{"body": "logout", "severity": "notice", "user_username": "akadmin", "event_user_username": "other"}`,
+			expectedCode:  http.StatusNoContent,
+			expectedCalls: 1,
+		},
+		{
+			name:          "with logout payload",
+			target:        "http://example.com/_authentik/webhook?secret=secret",
+			body:          `{"event": {"action": "logout", "user": {"pk": 5, "username": "jdoe"}, "context": {}}}`,
+			expectedCode:  http.StatusNoContent,
+			expectedCalls: 2,
+		},
+		{
+			name:          "with user write payload",
+			target:        "http://example.com/_authentik/webhook?secret=secret",
+			body:          `{"action": "user_write", "user": {"pk": 5, "username": "jdoe"}, "context": {"name": "jdoe"}}`,
+			expectedCode:  http.StatusNoContent,
+			expectedCalls: 2,
+		},
+		{
+			name:          "with unsupported action payload",
+			target:        "http://example.com/_authentik/webhook?secret=secret",
+			body:          `{"event": {"action": "login", "user": {"pk": 5, "username": "jdoe"}, "context": {}}}`,
+			expectedCode:  http.StatusBadRequest,
+			expectedCalls: 1,
+		},
+		{
+			name:          "with invalid secret",
+			target:        "http://example.com/_authentik/webhook?secret=invalid",
+			body:          `{"context": {"model": {"model_name": "user", "name": "jdoe"}}}`,
+			expectedCode:  http.StatusUnauthorized,
+			expectedCalls: 1,
+		},
+		{
+			name:          "with non bearer authorization",
+			target:        "http://example.com/_authentik/webhook?secret=secret",
+			authorization: "secret",
+			body:          `{"context": {"model": {"model_name": "user", "name": "jdoe"}}}`,
+			expectedCode:  http.StatusUnauthorized,
+			expectedCalls: 1,
+		},
+		{
+			name:          "with empty bearer token",
+			target:        "http://example.com/_authentik/webhook",
+			authorization: "Bearer ",
+			body:          `{"context": {"model": {"model_name": "user", "name": "jdoe"}}}`,
+			expectedCode:  http.StatusUnauthorized,
+			expectedCalls: 1,
+		},
+		{
+			name:          "with invalid payload",
+			target:        "http://example.com/_authentik/webhook?secret=secret",
+			body:          `{"severity": "notice"}`,
+			expectedCode:  http.StatusBadRequest,
+			expectedCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			akCalls := 0
+			handler := newHandler(t, &akCalls)
+
+			serveUpstream(handler)
+
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+
+			if rw.Code != tt.expectedCode {
+				t.Errorf("expected status %d, got %d", tt.expectedCode, rw.Code)
+			}
+
+			serveUpstream(handler)
+
+			// check whether the cached session was dropped
+			if akCalls != tt.expectedCalls {
+				t.Errorf("expected %d authentik calls, got %d", tt.expectedCalls, akCalls)
+			}
+		})
+	}
+
+	t.Run("with get request", func(t *testing.T) {
+		akCalls := 0
+		handler := newHandler(t, &akCalls)
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/_authentik/webhook?secret=secret", nil)
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+
+		expectedCode := http.StatusMethodNotAllowed
+		if rw.Code != expectedCode {
+			t.Errorf("expected status %d, got %d", expectedCode, rw.Code)
+		}
+	})
+}
+
+func TestServeHTTP_Denylist(t *testing.T) {
+	t.Run("request of denied user", func(t *testing.T) {
+		akCalls := 0
+		akServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			akCalls++
+
+			rw.Header().Set("X-Authentik-Username", "jdoe")
+			rw.WriteHeader(http.StatusOK)
+		}))
+		defer akServer.Close()
+
+		next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusOK)
+		})
+
+		path := filepath.Join(t.TempDir(), "denylist")
+		if err := os.WriteFile(path, []byte("username:other\n"), 0o600); err != nil {
+			t.Fatalf("failed to write denylist: %v", err)
+		}
+
+		config := &config.Config{
+			Address:            akServer.URL,
+			CacheDuration:      "1m",
+			RedirectStatusCode: http.StatusFound,
+			RedirectPaths:      []string{"^/.*$"},
+			UnauthorizedPaths:  []string{},
+			DenylistFile:       path,
+			DenylistInterval:   "10ms",
+		}
+		handler, err := plugin.New(context.Background(), next, config, "test")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		serve := func() *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/users", nil)
+			req.AddCookie(&http.Cookie{Name: "authentik_proxy_session", Value: "test-session"})
+
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+
+			return rw
+		}
+
+		if rw := serve(); rw.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rw.Code)
+		}
+
+		// deny the user while its session is cached
+		if err := os.WriteFile(path, []byte("username:jdoe\n"), 0o600); err != nil {
+			t.Fatalf("failed to write denylist: %v", err)
+		}
+
+		var rw *httptest.ResponseRecorder
+		for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if rw = serve(); rw.Code != http.StatusOK {
+				break
+			}
+		}
+
+		// check that the denied user is not redirected back to authentik
+		expectedCode := http.StatusUnauthorized
+		if rw.Code != expectedCode {
+			t.Fatalf("expected status %d, got %d", expectedCode, rw.Code)
+		}
+
+		if rw.Header().Get("Location") != "" {
+			t.Errorf("expected no Location header, got %s", rw.Header().Get("Location"))
+		}
+
+		// check that the cached session was evicted
+		calls := akCalls
+		serve()
+
+		if akCalls != calls+1 {
+			t.Errorf("expected %d authentik calls, got %d", calls+1, akCalls)
+		}
+	})
+}
+
+func TestServeHTTP_Metrics(t *testing.T) {
+	newHandler := func(t *testing.T, allowedIPs []string, token string) http.Handler {
+		t.Helper()
+
+		return newTestHandler(t, func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusUnauthorized)
+		}, &config.Config{
+			UnauthorizedPaths: []string{"^/api/.*$"},
+			MetricsPath:       "/_authentik/metrics",
+			MetricsAllowedIPs: allowedIPs,
+			MetricsToken:      token,
+		})
+	}
+
+	t.Run("request with allowed ip", func(t *testing.T) {
+		handler := newHandler(t, []string{"192.0.2.0/24"}, "")
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/api/users", nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		req = httptest.NewRequest(http.MethodGet, "http://example.com/_authentik/metrics", nil)
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rw.Code)
+		}
+
+		body := rw.Body.String()
+		for _, expected := range []string{
+			`traefik_authentik_checks_total{outcome="unauthenticated"} 1`,
+			`traefik_authentik_cache_requests_total{result="bypass"} 1`,
+			`traefik_authentik_decisions_total{rule="^/api/.*$",decision="unauthorized"} 1`,
+			`traefik_authentik_request_duration_seconds_count 1`,
+			`traefik_authentik_circuit_breaker_state 0`,
+		} {
+			if !strings.Contains(body, expected) {
+				t.Errorf("expected metrics to contain %s, got:\n%s", expected, body)
+			}
+		}
+	})
+
+	t.Run("request with forbidden ip", func(t *testing.T) {
+		handler := newHandler(t, []string{"10.0.0.0/8"}, "")
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/_authentik/metrics", nil)
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+
+		expectedCode := http.StatusForbidden
+		if rw.Code != expectedCode {
+			t.Errorf("expected status %d, got %d", expectedCode, rw.Code)
+		}
+	})
+
+	t.Run("request with token", func(t *testing.T) {
+		handler := newHandler(t, nil, "secret")
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/_authentik/metrics", nil)
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+
+		expectedCode := http.StatusForbidden
+		if rw.Code != expectedCode {
+			t.Errorf("expected status %d, got %d", expectedCode, rw.Code)
+		}
+
+		req = httptest.NewRequest(http.MethodGet, "http://example.com/_authentik/metrics", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rw = httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+
+		expectedCode = http.StatusOK
+		if rw.Code != expectedCode {
+			t.Errorf("expected status %d, got %d", expectedCode, rw.Code)
+		}
+	})
+}
+
+func TestServeHTTP_AuditLog(t *testing.T) {
+	t.Run("request of authenticated user", func(t *testing.T) {
+		akServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("X-Authentik-Uid", "5f8e1b6c")
+			rw.Header().Set("X-Authentik-Username", "jdoe")
+			rw.WriteHeader(http.StatusOK)
+		}))
+		defer akServer.Close()
+
+		next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusOK)
+		})
+
+		path := filepath.Join(t.TempDir(), "audit.log")
+
+		config := &config.Config{
+			Address:      akServer.URL,
+			SkippedPaths: []string{"^/health$"},
+			AuditLog: config.AuditLogConfig{
+				Output: path,
+			},
+		}
+		handler, err := plugin.New(context.Background(), next, config, "test")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/users", nil)
+		req.AddCookie(&http.Cookie{Name: "authentik_proxy_session", Value: "test-session"})
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		req = httptest.NewRequest(http.MethodGet, "http://example.com/health", nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected 2 lines, got %d", len(lines))
+		}
+
+		for _, expected := range []string{`"decision":"allowed"`, `"username":"jdoe"`, `"uid":"5f8e1b6c"`, `"path":"/users"`} {
+			if !strings.Contains(lines[0], expected) {
+				t.Errorf("expected entry to contain %s, got %s", expected, lines[0])
+			}
+		}
+
+		for _, expected := range []string{`"decision":"skipped"`, `"rule":"^/health$"`} {
+			if !strings.Contains(lines[1], expected) {
+				t.Errorf("expected entry to contain %s, got %s", expected, lines[1])
+			}
+		}
+	})
+
+	t.Run("request to outpost", func(t *testing.T) {
+		akServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusFound)
+		}))
+		defer akServer.Close()
+
+		next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusOK)
+		})
+
+		path := filepath.Join(t.TempDir(), "audit.log")
+
+		config := &config.Config{
+			Address: akServer.URL,
+			AuditLog: config.AuditLogConfig{
+				Output: path,
+			},
+		}
+		handler, err := plugin.New(context.Background(), next, config, "test")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/outpost.goauthentik.io/sign_out", nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		req = httptest.NewRequest(http.MethodPost, "http://example.com/outpost.goauthentik.io/sign_out", nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected 2 lines, got %d", len(lines))
+		}
+
+		for _, expected := range []string{`"decision":"proxied"`, `"path":"/outpost.goauthentik.io/sign_out"`} {
+			if !strings.Contains(lines[0], expected) {
+				t.Errorf("expected entry to contain %s, got %s", expected, lines[0])
+			}
+		}
+
+		for _, expected := range []string{`"decision":"denied"`, `"method":"POST"`} {
+			if !strings.Contains(lines[1], expected) {
+				t.Errorf("expected entry to contain %s, got %s", expected, lines[1])
+			}
+		}
+	})
+}
+
+func TestServeHTTP_GroupPaths(t *testing.T) {
+	akServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if _, err := req.Cookie("authentik_proxy_session"); err != nil {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		rw.Header().Set("X-Authentik-Username", "jdoe")
+		rw.Header().Set("X-Authentik-Groups", "users|operators")
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer akServer.Close()
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	config := &config.Config{
+		Address:             akServer.URL,
+		ForbiddenStatusCode: http.StatusNotFound,
+		Rules: []config.RuleConfig{
+			{Path: "^/audit", Action: "redirect", Groups: []string{"auditors"}},
+		},
+		UnauthorizedPaths: []string{"^/private"},
+		GroupPaths: []config.GroupPathConfig{
+			{Path: "^/admin", Groups: []string{"admins", "operators"}},
+			{Path: "^/admin/billing", Groups: []string{"admins", "billing"}},
+			{Path: "^/public/admin", Groups: []string{"operators"}},
+		},
+	}
+	handler, err := plugin.New(context.Background(), next, config, "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		authenticated bool
+		expectedCode  int
+	}{
+		{"request of user in any group", "/admin", true, http.StatusOK},
+		{"request of user not in groups", "/admin/billing", true, http.StatusNotFound},
+		{"request of path without group rule", "/private", true, http.StatusOK},
+		{"request of anonymous user", "/public/admin", false, http.StatusUnauthorized},
+		{"request of user not in rule groups", "/audit", true, http.StatusNotFound},
+		{"request of anonymous user to rule", "/audit", false, http.StatusFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com"+tt.path, nil)
+			if tt.authenticated {
+				req.AddCookie(&http.Cookie{Name: "authentik_proxy_session", Value: "test-session"})
+			}
+
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+
+			if rw.Code != tt.expectedCode {
+				t.Errorf("expected status %d, got %d", tt.expectedCode, rw.Code)
+			}
+		})
+	}
+}
+
+func TestServeHTTP_GroupPathsWithRedirectPaths(t *testing.T) {
+	akServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if _, err := req.Cookie("authentik_proxy_session"); err != nil {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		rw.Header().Set("X-Authentik-Username", "jdoe")
+		rw.Header().Set("X-Authentik-Groups", "users")
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer akServer.Close()
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	config := &config.Config{
+		Address:           akServer.URL,
+		UnauthorizedPaths: []string{"^/admin/api"},
+		RedirectPaths:     []string{"^/"},
+		GroupPaths: []config.GroupPathConfig{
+			{Path: "^/admin", Groups: []string{"admins"}},
+		},
+	}
+	handler, err := plugin.New(context.Background(), next, config, "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		authenticated bool
+		expectedCode  int
+	}{
+		{"request of anonymous user to redirect path", "/admin", false, http.StatusFound},
+		{"request of anonymous user to unauthorized path", "/admin/api", false, http.StatusUnauthorized},
+		{"request of user not in groups", "/admin", true, http.StatusForbidden},
+		{"request of user to path without group", "/users", true, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com"+tt.path, nil)
+			if tt.authenticated {
+				req.AddCookie(&http.Cookie{Name: "authentik_proxy_session", Value: "test-session"})
+			}
+
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+
+			if rw.Code != tt.expectedCode {
+				t.Errorf("expected status %d, got %d", tt.expectedCode, rw.Code)
+			}
+		})
+	}
+}
+
+func TestServeHTTP_Rules(t *testing.T) {
+	akCalls := 0
+	akServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		akCalls++
+		rw.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer akServer.Close()
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	config := &config.Config{
+		Address: akServer.URL,
+		Rules: []config.RuleConfig{
+			{Path: "^/api", Methods: []string{"GET"}, Query: map[string]string{"public": "^true$"}, Action: "skip"},
+			{Path: "^/api", Methods: []string{"GET", "HEAD"}, Action: "allow"},
+			{Path: "^/api", Headers: map[string]string{"Accept": "text/html"}, Action: "redirect", StatusCode: http.StatusSeeOther},
+			{Path: "^/api", Action: "deny", StatusCode: http.StatusForbidden},
+		},
+		UnauthorizedPaths: []string{},
+	}
+	handler, err := plugin.New(context.Background(), next, config, "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		method          string
+		target          string
+		accept          string
+		expectedCode    int
+		expectedAKCalls int
+	}{
+		{"request matching skip rule", http.MethodGet, "/api/users?public=true", "", http.StatusOK, 0},
+		{"request matching allow rule", http.MethodGet, "/api/users", "", http.StatusOK, 1},
+		{"request matching redirect rule", http.MethodPost, "/api/users", "text/html", http.StatusSeeOther, 1},
+		{"request matching deny rule", http.MethodPost, "/api/users", "", http.StatusForbidden, 1},
+		{"request matching no rule", http.MethodPost, "/users", "", http.StatusOK, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			akCalls = 0
+
+			req := httptest.NewRequest(tt.method, "http://example.com"+tt.target, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+
+			if rw.Code != tt.expectedCode {
+				t.Errorf("expected status %d, got %d", tt.expectedCode, rw.Code)
+			}
+
+			if akCalls != tt.expectedAKCalls {
+				t.Errorf("expected %d authentik calls, got %d", tt.expectedAKCalls, akCalls)
+			}
+		})
+	}
+}
+
+func TestServeHTTP_HostRules(t *testing.T) {
+	akServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer akServer.Close()
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	config := &config.Config{
+		Address: akServer.URL,
+		Rules: []config.RuleConfig{
+			{Path: "^/api", Hosts: []string{"public.example.com"}, Action: "allow"},
+			{Path: "^/api", Hosts: []string{"*.internal.example.com", `~^admin\.`}, Action: "redirect"},
+		},
+		UnauthorizedPaths: []string{"^/.*$"},
+	}
+	handler, err := plugin.New(context.Background(), next, config, "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		host         string
+		expectedCode int
+	}{
+		{"request to exact host", "public.example.com", http.StatusOK},
+		{"request to exact host with port", "public.example.com:8443", http.StatusOK},
+		{"request to wildcard host", "api.internal.example.com", http.StatusFound},
+		{"request to regex host", "admin.example.com", http.StatusFound},
+		{"request to other host", "internal.example.com", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://"+tt.host+"/api/users", nil)
+
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+
+			if rw.Code != tt.expectedCode {
+				t.Errorf("expected status %d, got %d", tt.expectedCode, rw.Code)
+			}
+		})
+	}
+}
+
+func TestServeHTTP_CORSPreflight(t *testing.T) {
+	akCalls := 0
+	akServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		akCalls++
+		rw.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer akServer.Close()
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name            string
+		enabled         bool
+		origins         []string
+		method          string
+		origin          string
+		expectedCode    int
+		expectedAKCalls int
+	}{
+		{"preflight request with bypass disabled", false, nil, http.MethodOptions, "https://app.example.com", http.StatusUnauthorized, 1},
+		{"preflight request with bypass enabled", true, nil, http.MethodOptions, "https://app.example.com", http.StatusNoContent, 0},
+		{"preflight request from allowed origin", true, []string{"https://app.example.com"}, http.MethodOptions, "https://app.example.com", http.StatusNoContent, 0},
+		{"preflight request from other origin", true, []string{"https://app.example.com"}, http.MethodOptions, "https://evil.example.com", http.StatusUnauthorized, 1},
+		{"cross origin request with bypass enabled", true, nil, http.MethodPost, "https://app.example.com", http.StatusUnauthorized, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			akCalls = 0
+
+			config := &config.Config{
+				Address:            akServer.URL,
+				CORSPreflight:      tt.enabled,
+				CORSAllowedOrigins: tt.origins,
+				UnauthorizedPaths:  []string{"^/.*$"},
+			}
+			handler, err := plugin.New(context.Background(), next, config, "test")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			req := httptest.NewRequest(tt.method, "http://example.com/api/users", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+
+			if rw.Code != tt.expectedCode {
+				t.Errorf("expected status %d, got %d", tt.expectedCode, rw.Code)
+			}
+
+			if akCalls != tt.expectedAKCalls {
+				t.Errorf("expected %d authentik calls, got %d", tt.expectedAKCalls, akCalls)
+			}
+		})
+	}
+}
+
+func TestServeHTTP_Negotiate(t *testing.T) {
+	akServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer akServer.Close()
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	config := &config.Config{
+		Address: akServer.URL,
+		Rules: []config.RuleConfig{
+			{Path: "^/", Action: "negotiate"},
+		},
+	}
+	handler, err := plugin.New(context.Background(), next, config, "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedLoginURL := "http://example.com/outpost.goauthentik.io/start?rd=http%3A%2F%2Fexample.com%2Fapp"
+
+	t.Run("request of navigating browser", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/app", nil)
+		req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8")
+		req.Header.Set("Sec-Fetch-Mode", "navigate")
+
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusFound {
+			t.Fatalf("expected status %d, got %d", http.StatusFound, rw.Code)
+		}
+
+		if rw.Header().Get("Location") != expectedLoginURL {
+			t.Errorf("expected Location %s, got %s", expectedLoginURL, rw.Header().Get("Location"))
+		}
+
+		if len(rw.Header().Values("Vary")) == 0 {
+			t.Error("expected Vary header to be set")
+		}
+	})
+
+	t.Run("request of fetch client", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/app", nil)
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Sec-Fetch-Mode", "cors")
+
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+
+		if rw.Code != http.StatusUnauthorized {
+			t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, rw.Code)
+		}
+
+		if rw.Header().Get("Location") != "" {
+			t.Errorf("expected no Location header, got %s", rw.Header().Get("Location"))
+		}
+
+		if rw.Header().Get("X-Authentik-Traefik-Login-Url") != expectedLoginURL {
+			t.Errorf("expected login url header %s, got %s", expectedLoginURL, rw.Header().Get("X-Authentik-Traefik-Login-Url"))
+		}
+
+		if rw.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("expected json content type, got %s", rw.Header().Get("Content-Type"))
+		}
+
+		var body struct {
+			Error    string `json:"error"`
+			LoginURL string `json:"loginUrl"`
+		}
+		if err := json.Unmarshal(rw.Body.Bytes(), &body); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if body.LoginURL != expectedLoginURL {
+			t.Errorf("expected login url %s, got %s", expectedLoginURL, body.LoginURL)
+		}
+
+		if body.Error != "Unauthorized" {
+			t.Errorf("expected error Unauthorized, got %s", body.Error)
+		}
+	})
+}
+
+// newTestHandler starts a fake authentik server served by akHandler, and
+// returns the middleware configured by cfg against it, in front of an upstream
+// answering every request with 200.
+func newTestHandler(t *testing.T, akHandler http.HandlerFunc, cfg *config.Config) http.Handler {
+	t.Helper()
+
+	akServer := httptest.NewServer(akHandler)
+	t.Cleanup(akServer.Close)
+
+	next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+
+	cfg.Address = akServer.URL
+
+	handler, err := plugin.New(context.Background(), next, cfg, "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return handler
+}